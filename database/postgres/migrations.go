@@ -0,0 +1,8 @@
+// Package postgres holds the schema migrations, embedded so that the service
+// can bring any database up to date when it starts.
+package postgres
+
+import "embed"
+
+//go:embed migrations/*.sql
+var Migrations embed.FS
\ No newline at end of file
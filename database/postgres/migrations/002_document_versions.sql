@@ -0,0 +1,18 @@
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS document_versions (
+    document_id  UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    version      INTEGER NOT NULL,
+    mime         TEXT NOT NULL,
+    file         BOOLEAN NOT NULL DEFAULT false,
+    json_data    JSONB,
+    file_path    TEXT,
+    created_by   TEXT,
+    created_at   TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (document_id, version)
+);
+
+INSERT INTO document_versions (document_id, version, mime, file, json_data, file_path, created_by, created_at)
+SELECT id, version, mime, file, json_data, file_path, owner_login, created_at
+FROM documents
+ON CONFLICT DO NOTHING;
\ No newline at end of file
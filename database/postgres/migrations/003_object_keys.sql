@@ -0,0 +1,17 @@
+-- file_path used to hold absolute paths of the local storage; it now holds
+-- backend-neutral object keys relative to the storage root.
+UPDATE documents
+SET file_path = substring(file_path from '([0-9a-f-]{36}/[0-9]+/[^/]+)$')
+WHERE file_path LIKE '/%' AND file_path ~ '/[0-9a-f-]{36}/[0-9]+/[^/]+$';
+
+UPDATE documents
+SET file_path = regexp_replace(file_path, '^.*/', '')
+WHERE file_path LIKE '/%';
+
+UPDATE document_versions
+SET file_path = substring(file_path from '([0-9a-f-]{36}/[0-9]+/[^/]+)$')
+WHERE file_path LIKE '/%' AND file_path ~ '/[0-9a-f-]{36}/[0-9]+/[^/]+$';
+
+UPDATE document_versions
+SET file_path = regexp_replace(file_path, '^.*/', '')
+WHERE file_path LIKE '/%';
\ No newline at end of file
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS blobs (
+    checksum    TEXT PRIMARY KEY,
+    file_path   TEXT NOT NULL,
+    size        BIGINT NOT NULL,
+    ref_count   INTEGER NOT NULL DEFAULT 0,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Files stored before content addressing keep their old keys and have no
+-- checksum; they are deleted together with the document as before.
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS checksum TEXT;
+ALTER TABLE document_versions ADD COLUMN IF NOT EXISTS checksum TEXT;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'editor'
+    CHECK (role IN ('admin', 'editor', 'viewer'));
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS sessions_created_at_idx ON sessions (created_at);
+CREATE INDEX IF NOT EXISTS sessions_last_seen_at_idx ON sessions (last_seen_at);
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- Tokens used to be stored in plaintext. Existing sessions cannot be
+-- converted without the raw tokens being exposed, so they are dropped and
+-- users have to log in again.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'sessions' AND column_name = 'token'
+    ) THEN
+        DELETE FROM sessions;
+        ALTER TABLE sessions RENAME COLUMN token TO token_hash;
+    END IF;
+END $$;
\ No newline at end of file
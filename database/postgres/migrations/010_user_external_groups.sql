@@ -0,0 +1,2 @@
+-- Groups reported by an external directory (LDAP) at the user's last login.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS external_groups TEXT[] NOT NULL DEFAULT '{}';
\ No newline at end of file
@@ -0,0 +1,28 @@
+-- Per-grantee permissions replace the flat grant_list. A grantee is a login
+-- or "group:<name>"; any entry lets the grantee read the document.
+CREATE TABLE IF NOT EXISTS document_acl (
+    document_id UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    grantee     TEXT NOT NULL,
+    permissions TEXT[] NOT NULL,
+    granted_by  TEXT,
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (document_id, grantee)
+);
+
+CREATE INDEX IF NOT EXISTS document_acl_grantee_idx ON document_acl (grantee);
+
+-- Logins in the old grant_list could only read.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'documents' AND column_name = 'grant_list'
+    ) THEN
+        INSERT INTO document_acl (document_id, grantee, permissions, granted_by)
+        SELECT DISTINCT d.id, g.grantee, '{read}'::TEXT[], d.owner_login
+        FROM documents d, unnest(d.grant_list) AS g(grantee)
+        WHERE g.grantee <> ''
+        ON CONFLICT DO NOTHING;
+        ALTER TABLE documents DROP COLUMN grant_list;
+    END IF;
+END $$;
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- Links that let anyone holding them download a document without logging in.
+-- The token handed out is signed; only its id is stored.
+CREATE TABLE IF NOT EXISTS share_links (
+    id            TEXT PRIMARY KEY,
+    document_id   UUID NOT NULL REFERENCES documents(id) ON DELETE CASCADE,
+    created_by    TEXT NOT NULL,
+    expires_at    TIMESTAMP NOT NULL,
+    password_hash TEXT,
+    max_downloads INTEGER,
+    downloads     INTEGER NOT NULL DEFAULT 0,
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS share_links_document_id_idx ON share_links (document_id);
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- Lets listings filter on fields inside json_data. jsonb_path_ops serves the
+-- containment (@>) lookups equality filters compile to.
+CREATE INDEX IF NOT EXISTS documents_json_data_idx ON documents USING GIN (json_data jsonb_path_ops);
\ No newline at end of file
@@ -0,0 +1,7 @@
+-- Full-text search. search_vector is kept up to date by the application,
+-- which knows the configured text search language; search_language records
+-- the language each vector was made with, so a change can be caught up on.
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS search_vector tsvector;
+ALTER TABLE documents ADD COLUMN IF NOT EXISTS search_language TEXT;
+
+CREATE INDEX IF NOT EXISTS documents_search_vector_idx ON documents USING GIN (search_vector);
\ No newline at end of file
@@ -0,0 +1,12 @@
+-- Plain text extracted from uploaded files, one row per document for its
+-- current version. status is pending until a worker has looked at the file.
+CREATE TABLE IF NOT EXISTS document_content (
+    document_id UUID PRIMARY KEY REFERENCES documents(id) ON DELETE CASCADE,
+    version     INTEGER NOT NULL,
+    status      TEXT NOT NULL DEFAULT 'pending',
+    text        TEXT,
+    error       TEXT,
+    updated_at  TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS document_content_pending_idx ON document_content (updated_at) WHERE status = 'pending';
\ No newline at end of file
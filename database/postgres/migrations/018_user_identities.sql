@@ -0,0 +1,13 @@
+-- Accounts at an OIDC identity provider linked to local users. Logins are
+-- matched by the provider's issuer and subject, never by a name claim.
+-- Users created by SSO before this table existed are not linked and cannot
+-- log in through SSO until an admin links them.
+CREATE TABLE IF NOT EXISTS user_identities (
+    issuer     TEXT NOT NULL,
+    subject    TEXT NOT NULL,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON user_identities (user_id);
\ No newline at end of file
@@ -0,0 +1,14 @@
+-- Where an account comes from. 'local' accounts log in with their own
+-- password; 'ldap' and 'oidc' ones were created by that outside source on
+-- first login and can only be logged into through it.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS auth_source TEXT NOT NULL DEFAULT 'local';
+
+-- Accounts without a password were created by SSO or by the directory.
+-- Which one made those not linked to an SSO identity was not recorded;
+-- they are taken for directory accounts.
+UPDATE users SET auth_source = 'oidc'
+WHERE auth_source = 'local' AND password_hash = ''
+  AND id IN (SELECT user_id FROM user_identities);
+
+UPDATE users SET auth_source = 'ldap'
+WHERE auth_source = 'local' AND password_hash = '';
\ No newline at end of file
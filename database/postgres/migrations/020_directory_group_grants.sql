@@ -0,0 +1,10 @@
+-- Directory groups are now granted to as "ldap:<name>", apart from local
+-- groups. A "group:<name>" grant with no local group of that name could
+-- only have meant the directory group.
+UPDATE document_acl a SET grantee = 'ldap:' || substr(a.grantee, 7)
+WHERE a.grantee LIKE 'group:%'
+  AND NOT EXISTS (SELECT 1 FROM groups g WHERE 'group:' || g.name = a.grantee)
+  AND NOT EXISTS (
+      SELECT 1 FROM document_acl b
+      WHERE b.document_id = a.document_id AND b.grantee = 'ldap:' || substr(a.grantee, 7)
+  );
\ No newline at end of file
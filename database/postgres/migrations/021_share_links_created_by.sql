@@ -0,0 +1,2 @@
+-- Share links are listed and revoked by whoever issued them.
+CREATE INDEX IF NOT EXISTS share_links_created_by_idx ON share_links (created_by);
\ No newline at end of file
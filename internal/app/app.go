@@ -2,9 +2,11 @@ package app
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 	"net/http"
@@ -16,9 +18,11 @@ import (
 	service "docs_storage/internal/service"
 	db "docs_storage/pkg/db"
 	logger "docs_storage/pkg/logger"
+	ratelimit "docs_storage/pkg/ratelimit"
 	repository "docs_storage/internal/repository"
 	storage "docs_storage/internal/storage"
 	cache "docs_storage/internal/cache"
+	migrations "docs_storage/database/postgres"
 )
 
 type App struct {
@@ -55,23 +59,171 @@ func (a *App) Run() error {
 	}
 	defer postgres.Close()
 
-	docsRepo := repository.NewDocsRepo(postgres.Pool)
+	applied, err := postgres.Migrate(ctx, migrations.Migrations, "migrations")
+	if err != nil {
+		a.logger.Error.Println("Failed to migrate database:", err)
+		return err
+	}
+	if len(applied) > 0 {
+		a.logger.Info.Printf("Applied migrations: %s", strings.Join(applied, ", "))
+	}
+
+	if !repository.ValidSearchLanguage(a.config.Search.language) {
+		err := fmt.Errorf("unknown search language %q", a.config.Search.language)
+		a.logger.Error.Println("Failed to initialize search:", err)
+		return err
+	}
+	docsRepo := repository.NewDocsRepo(postgres.Pool, a.config.Search.language)
 	userRepo := repository.NewUserRepo(postgres.Pool)
-	sessionRepo := repository.NewSessionRepo(postgres.Pool)
+	sessionRepo := repository.NewSessionRepo(
+		postgres.Pool,
+		time.Duration(a.config.Session.ttl)*time.Second,
+		time.Duration(a.config.Session.idleTimeout)*time.Second,
+	)
+	blobRepo := repository.NewBlobRepo(postgres.Pool)
+	apiKeyRepo := repository.NewAPIKeyRepo(postgres.Pool)
+	mfaRepo := repository.NewMFARepo(postgres.Pool, service.MFAChallengeTTL)
+	groupRepo := repository.NewGroupRepo(postgres.Pool)
+	shareLinkRepo := repository.NewShareLinkRepo(postgres.Pool)
 
-	fileStorage := storage.NewLocalFileStorage(a.config.FileStorage.path)
+	backend, err := storage.New(ctx, storage.Config{
+		Backend:   a.config.FileStorage.backend,
+		LocalPath: a.config.FileStorage.path,
+		S3: storage.S3Config{
+			Endpoint:  a.config.FileStorage.s3.endpoint,
+			AccessKey: a.config.FileStorage.s3.accessKey,
+			SecretKey: a.config.FileStorage.s3.secretKey,
+			Bucket:    a.config.FileStorage.s3.bucket,
+			Region:    a.config.FileStorage.s3.region,
+			UseSSL:    a.config.FileStorage.s3.useSSL,
+		},
+	})
+	if err != nil {
+		a.logger.Error.Println("Failed to initialize file storage:", err)
+		return err
+	}
+	fileStorage := storage.NewBlobStorage(backend)
+	// Login failure counters live in their own cache so that document churn
+	// cannot evict them.
+	attempts := cache.NewLFUCache(a.config.Lockout.capacity)
 	cache := cache.NewLFUCache(a.config.Cache.capacity)
 
-	docsSvc := service.NewDocsService(docsRepo, fileStorage, sessionRepo, cache)
-	authSvc := service.NewAuthService(userRepo, sessionRepo, a.config.Admin.token)
+	uploadStore := storage.NewUploadStore(a.config.FileStorage.path)
+
+	jwtManager, err := a.newJWTManager()
+	if err != nil {
+		a.logger.Error.Println("Failed to initialize auth:", err)
+		return err
+	}
+
+	credentials, err := a.newCredentialVerifier(userRepo)
+	if err != nil {
+		a.logger.Error.Println("Failed to initialize auth:", err)
+		return err
+	}
+
+	loginLimiter := service.NewLoginLimiter(attempts, service.LockoutPolicy{
+		LoginFreeAttempts: a.config.Lockout.maxFailures,
+		IPFreeAttempts:    a.config.Lockout.ipMaxFailures,
+		BaseDelay:         time.Duration(a.config.Lockout.backoffBase) * time.Second,
+		MaxDelay:          time.Duration(a.config.Lockout.maxLockout) * time.Second,
+		Window:            time.Duration(a.config.Lockout.failureWindow) * time.Second,
+	})
+
+	authSvc := service.NewAuthService(userRepo, credentials, sessionRepo, mfaRepo, apiKeyRepo, jwtManager, loginLimiter, a.config.Admin.token)
+	extractor := service.NewExtractor(docsRepo, fileStorage, cache, a.config.Extraction.queueSize, a.config.Extraction.maxSize)
+	docsSvc := service.NewDocsService(docsRepo, blobRepo, fileStorage, authSvc, cache, extractor)
+	userSvc := service.NewUserService(userRepo, sessionRepo, authSvc, docsSvc)
+	apiKeySvc := service.NewAPIKeyService(apiKeyRepo, authSvc)
+	mfaSvc := service.NewMFAService(mfaRepo, authSvc, a.config.MFA.totpIssuer)
+	lockoutSvc := service.NewLockoutService(loginLimiter, authSvc)
+	groupSvc := service.NewGroupService(groupRepo, authSvc, cache)
+	shareLinkSecret, err := a.shareLinkSecret()
+	if err != nil {
+		a.logger.Error.Println("Failed to initialize share links:", err)
+		return err
+	}
+	shareLinkSvc := service.NewShareLinkService(shareLinkRepo, docsRepo, authSvc, service.ShareLinkPolicy{
+		Secret:     shareLinkSecret,
+		DefaultTTL: time.Duration(a.config.ShareLink.defaultTTL) * time.Second,
+		MaxTTL:     time.Duration(a.config.ShareLink.maxTTL) * time.Second,
+	})
+	uploadSvc := service.NewUploadService(
+		uploadStore, docsSvc, authSvc,
+		a.config.FileStorage.maxUploadSize,
+		time.Duration(a.config.Upload.expiration)*time.Second,
+	)
 
-	docsHandler := handlers.NewDocsHandler(docsSvc, a.logger)
-	authHandler := handlers.NewAuthHandler(authSvc, a.logger)
+	go uploadSvc.RunSweeper(ctx, time.Duration(a.config.Upload.sweepInterval)*time.Second, func(err error) {
+		a.logger.Error.Println("Failed to sweep abandoned uploads:", err)
+	})
+	go docsSvc.RunStagedSweeper(ctx, time.Duration(a.config.Upload.sweepInterval)*time.Second, func(err error) {
+		a.logger.Error.Println("Failed to sweep staged files:", err)
+	})
+	// Catches up on documents indexed before search existed or under another
+	// language; they are missing from results until this is done.
+	go func() {
+		n, err := docsRepo.ReindexSearch(ctx)
+		if err != nil {
+			a.logger.Error.Println("Failed to reindex documents for search:", err)
+			return
+		}
+		if n > 0 {
+			a.logger.Info.Printf("Reindexed %d documents for search", n)
+		}
+	}()
+	go extractor.Run(ctx, a.config.Extraction.workers, time.Duration(a.config.Extraction.sweepInterval)*time.Second, func(err error) {
+		a.logger.Error.Println("Failed to extract document text:", err)
+	})
+	go authSvc.RunSessionCleanup(ctx, time.Duration(a.config.Session.cleanupInterval)*time.Second, func(err error) {
+		a.logger.Error.Println("Failed to delete expired sessions:", err)
+	})
+
+	// Requests without a session are only accepted when anonymous access
+	// is switched on.
+	var anonymousLimiter *ratelimit.Limiter
+	if cfg := a.config.Public; cfg.enabled {
+		anonymousLimiter = ratelimit.New(cfg.rateLimit, cfg.rateBurst, cfg.capacity)
+	}
+
+	docsHandler := handlers.NewDocsHandler(docsSvc, a.logger, a.config.FileStorage.maxUploadSize, anonymousLimiter, a.config.Server.trustProxy)
+	authHandler := handlers.NewAuthHandler(authSvc, a.logger, a.config.Server.trustProxy)
+	uploadHandler := handlers.NewUploadHandler(uploadSvc, a.logger)
+	userHandler := handlers.NewUserHandler(userSvc, a.logger)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeySvc, a.logger)
+	mfaHandler := handlers.NewMFAHandler(mfaSvc, a.logger)
+	lockoutHandler := handlers.NewLockoutHandler(lockoutSvc, a.logger)
+	groupHandler := handlers.NewGroupHandler(groupSvc, a.logger)
+	// Link passwords are guessed against the same bound on tracked keys as
+	// logins.
+	linkGuesses := ratelimit.New(a.config.ShareLink.passwordRate, a.config.ShareLink.passwordBurst, a.config.Lockout.capacity)
+	shareLinkHandler := handlers.NewShareLinkHandler(shareLinkSvc, docsSvc, a.logger, linkGuesses, a.config.Server.trustProxy)
 	
 	router := mux.NewRouter()
 
 	routes.SetupDocsRoutes(router, docsHandler)
 	routes.SetupAuthRoutes(router, authHandler)
+	routes.SetupUploadRoutes(router, uploadHandler)
+	routes.SetupUserRoutes(router, userHandler)
+	routes.SetupAPIKeyRoutes(router, apiKeyHandler)
+	routes.SetupMFARoutes(router, mfaHandler)
+	routes.SetupLockoutRoutes(router, lockoutHandler)
+	routes.SetupGroupRoutes(router, groupHandler)
+	routes.SetupShareLinkRoutes(router, shareLinkHandler)
+
+	// SSO is only offered when an identity provider is configured.
+	if cfg := a.config.OIDC; cfg.issuerURL != "" {
+		oidcSvc := service.NewOIDCService(service.OIDCConfig{
+			IssuerURL:    cfg.issuerURL,
+			ClientID:     cfg.clientID,
+			ClientSecret: cfg.clientSecret,
+			RedirectURL:  cfg.redirectURL,
+			Scopes:       cfg.scopes,
+			DefaultRole:  cfg.defaultRole,
+		}, userRepo, authSvc, authSvc)
+		oidcHandler := handlers.NewOIDCHandler(oidcSvc, a.logger, strings.HasPrefix(cfg.redirectURL, "https://"))
+		routes.SetupOIDCRoutes(router, oidcHandler)
+	}
 	
 	serverAddr := fmt.Sprintf("%s:%d", a.config.Server.Host, a.config.Server.Port)
 
@@ -103,3 +255,90 @@ func (a *App) Run() error {
 	a.logger.Info.Println("Server exited properly")
 	return nil
 }
+
+const (
+	authModeSession = "session"
+	authModeJWT     = "jwt"
+)
+
+// newJWTManager returns nil in session mode, which makes the auth service
+// fall back to opaque session tokens.
+func (a *App) newJWTManager() (*service.JWTManager, error) {
+	cfg := a.config.Auth
+	switch cfg.mode {
+	case authModeSession, "":
+		return nil, nil
+	case authModeJWT:
+	default:
+		return nil, fmt.Errorf("unknown auth mode %q", cfg.mode)
+	}
+
+	key := []byte(cfg.jwtSecret)
+	if cfg.jwtAlgorithm == service.JWTAlgorithmEdDSA {
+		pem, err := os.ReadFile(cfg.jwtPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key = pem
+	}
+
+	ttl := time.Duration(cfg.jwtAccessTTL) * time.Second
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
+	}
+	return service.NewJWTManager(cfg.jwtAlgorithm, key, ttl)
+}
+
+// shareLinkSecret falls back to a random key, which is fine for a single
+// instance as long as links need not survive a restart.
+func (a *App) shareLinkSecret() ([]byte, error) {
+	if secret := a.config.ShareLink.secret; secret != "" {
+		return []byte(secret), nil
+	}
+
+	a.logger.Info.Println("SHARE_LINK_SECRET is not set, share links will stop working on restart")
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+const (
+	ldapModeOff      = "off"
+	ldapModeFallback = "fallback"
+	ldapModeReplace  = "replace"
+)
+
+// newCredentialVerifier picks where /api/auth checks passwords: the local
+// users table, an LDAP directory, or the directory first and then the local
+// table.
+func (a *App) newCredentialVerifier(users *repository.UserRepo) (*service.CredentialChain, error) {
+	local := service.NewLocalCredentials(users)
+
+	cfg := a.config.LDAP
+	if cfg.mode == ldapModeOff || cfg.mode == "" {
+		return service.NewCredentialChain(local), nil
+	}
+
+	ldap := service.NewLDAPCredentials(service.LDAPConfig{
+		URL:                cfg.url,
+		StartTLS:           cfg.startTLS,
+		InsecureSkipVerify: cfg.insecureSkipVerify,
+		BindDN:             cfg.bindDN,
+		BindPassword:       cfg.bindPassword,
+		BaseDN:             cfg.baseDN,
+		UserFilter:         cfg.userFilter,
+		LoginAttribute:     cfg.loginAttribute,
+		GroupAttribute:     cfg.groupAttribute,
+		DefaultRole:        cfg.defaultRole,
+	})
+	switch cfg.mode {
+	case ldapModeFallback:
+		return service.NewCredentialChain(ldap, local), nil
+	case ldapModeReplace:
+		return service.NewCredentialChain(ldap), nil
+	default:
+		return nil, fmt.Errorf("unknown ldap mode %q", cfg.mode)
+	}
+}
@@ -3,6 +3,7 @@ package app
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 type Config struct {
@@ -11,12 +12,24 @@ type Config struct {
 	Admin       AdminConfig
 	Cache       CacheConfig
 	FileStorage FileStorageConfig
+	Upload      UploadConfig
+	Session     SessionConfig
+	Auth        AuthConfig
+	OIDC        OIDCConfig
+	LDAP        LDAPConfig
+	MFA         MFAConfig
+	Lockout     LockoutConfig
+	ShareLink   ShareLinkConfig
+	Public      PublicConfig
+	Search      SearchConfig
+	Extraction  ExtractionConfig
 }
 
 type ServerConfig struct {
 	Port            int
 	Host            string
 	ShutdownTimeout int
+	trustProxy      bool
 }
 
 type PostgresConfig struct {
@@ -37,11 +50,137 @@ type CacheConfig struct {
 }
 
 type FileStorageConfig struct {
-	path string
+	backend       string
+	path          string
+	maxUploadSize int64
+	s3            S3Config
+}
+
+type S3Config struct {
+	endpoint  string
+	accessKey string
+	secretKey string
+	bucket    string
+	region    string
+	useSSL    bool
+}
+
+type UploadConfig struct {
+	expiration    int
+	sweepInterval int
+}
+
+type SessionConfig struct {
+	ttl             int
+	idleTimeout     int
+	cleanupInterval int
+}
+
+type AuthConfig struct {
+	mode              string
+	jwtAlgorithm      string
+	jwtSecret         string
+	jwtPrivateKeyFile string
+	jwtAccessTTL      int
+}
+
+type OIDCConfig struct {
+	issuerURL    string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	scopes       []string
+	defaultRole  string
+}
+
+type LDAPConfig struct {
+	mode               string
+	url                string
+	startTLS           bool
+	insecureSkipVerify bool
+	bindDN             string
+	bindPassword       string
+	baseDN             string
+	userFilter         string
+	loginAttribute     string
+	groupAttribute     string
+	defaultRole        string
+}
+
+type MFAConfig struct {
+	totpIssuer string
+}
+
+type LockoutConfig struct {
+	maxFailures   int
+	ipMaxFailures int
+	backoffBase   int
+	maxLockout    int
+	failureWindow int
+	capacity      int
+}
+
+type ShareLinkConfig struct {
+	secret     string
+	defaultTTL int
+	maxTTL     int
+	// Password guesses per minute for one link from one address.
+	passwordRate  int
+	passwordBurst int
+}
+
+type PublicConfig struct {
+	enabled   bool
+	rateLimit int
+	rateBurst int
+	capacity  int
+}
+
+type SearchConfig struct {
+	language string
+}
+
+type ExtractionConfig struct {
+	workers       int
+	queueSize     int
+	maxSize       int64
+	sweepInterval int
 }
 
 func LoadConfig() (*Config, error) {
-	config := &Config{}
+	config := &Config{
+		Upload: UploadConfig{
+			expiration:    86400,
+			sweepInterval: 3600,
+		},
+		MFA: MFAConfig{totpIssuer: "docs_storage"},
+		Lockout: LockoutConfig{
+			maxFailures:   5,
+			ipMaxFailures: 50,
+			backoffBase:   1,
+			maxLockout:    900,
+			failureWindow: 3600,
+			capacity:      10000,
+		},
+		ShareLink: ShareLinkConfig{
+			defaultTTL:    604800,
+			maxTTL:        2592000,
+			passwordRate:  10,
+			passwordBurst: 5,
+		},
+		Search: SearchConfig{language: "russian"},
+		Public: PublicConfig{
+			rateLimit: 60,
+			rateBurst: 20,
+			capacity:  10000,
+		},
+		Extraction: ExtractionConfig{
+			workers:       2,
+			queueSize:     100,
+			maxSize:       20 << 20,
+			sweepInterval: 300,
+		},
+	}
 	loadEnvVars(config)
 	return config, nil
 }
@@ -60,6 +199,11 @@ func loadEnvVars(config *Config) {
 			config.Server.ShutdownTimeout = timeout
 		}
 	}
+	if envVal := os.Getenv("SERVER_TRUST_PROXY"); envVal != "" {
+		if trust, err := strconv.ParseBool(envVal); err == nil {
+			config.Server.trustProxy = trust
+		}
+	}
 
 	if envVal := os.Getenv("POSTGRES_HOST"); envVal != "" {
 		config.Postgres.Host = envVal
@@ -88,9 +232,245 @@ func loadEnvVars(config *Config) {
 		}
 	}
 
+	if envVal := os.Getenv("STORAGE_BACKEND"); envVal != "" {
+		config.FileStorage.backend = envVal
+	}
 	if envVal := os.Getenv("FILE_STORAGE_PATH"); envVal != "" {
 		config.FileStorage.path = envVal
 	}
+	if envVal := os.Getenv("FILE_MAX_UPLOAD_SIZE"); envVal != "" {
+		if size, err := strconv.ParseInt(envVal, 10, 64); err == nil {
+			config.FileStorage.maxUploadSize = size
+		}
+	}
+
+	if envVal := os.Getenv("S3_ENDPOINT"); envVal != "" {
+		config.FileStorage.s3.endpoint = envVal
+	}
+	if envVal := os.Getenv("S3_ACCESS_KEY"); envVal != "" {
+		config.FileStorage.s3.accessKey = envVal
+	}
+	if envVal := os.Getenv("S3_SECRET_KEY"); envVal != "" {
+		config.FileStorage.s3.secretKey = envVal
+	}
+	if envVal := os.Getenv("S3_BUCKET"); envVal != "" {
+		config.FileStorage.s3.bucket = envVal
+	}
+	if envVal := os.Getenv("S3_REGION"); envVal != "" {
+		config.FileStorage.s3.region = envVal
+	}
+	if envVal := os.Getenv("S3_USE_SSL"); envVal != "" {
+		if useSSL, err := strconv.ParseBool(envVal); err == nil {
+			config.FileStorage.s3.useSSL = useSSL
+		}
+	}
+
+	if envVal := os.Getenv("UPLOAD_EXPIRATION"); envVal != "" {
+		if expiration, err := strconv.Atoi(envVal); err == nil {
+			config.Upload.expiration = expiration
+		}
+	}
+	if envVal := os.Getenv("UPLOAD_SWEEP_INTERVAL"); envVal != "" {
+		if interval, err := strconv.Atoi(envVal); err == nil {
+			config.Upload.sweepInterval = interval
+		}
+	}
+
+	if envVal := os.Getenv("SESSION_TTL"); envVal != "" {
+		if ttl, err := strconv.Atoi(envVal); err == nil {
+			config.Session.ttl = ttl
+		}
+	}
+	if envVal := os.Getenv("SESSION_IDLE_TIMEOUT"); envVal != "" {
+		if timeout, err := strconv.Atoi(envVal); err == nil {
+			config.Session.idleTimeout = timeout
+		}
+	}
+	if envVal := os.Getenv("SESSION_CLEANUP_INTERVAL"); envVal != "" {
+		if interval, err := strconv.Atoi(envVal); err == nil {
+			config.Session.cleanupInterval = interval
+		}
+	}
+
+	if envVal := os.Getenv("AUTH_MODE"); envVal != "" {
+		config.Auth.mode = envVal
+	}
+	if envVal := os.Getenv("JWT_ALGORITHM"); envVal != "" {
+		config.Auth.jwtAlgorithm = envVal
+	}
+	if envVal := os.Getenv("JWT_SECRET"); envVal != "" {
+		config.Auth.jwtSecret = envVal
+	}
+	if envVal := os.Getenv("JWT_PRIVATE_KEY_FILE"); envVal != "" {
+		config.Auth.jwtPrivateKeyFile = envVal
+	}
+	if envVal := os.Getenv("JWT_ACCESS_TTL"); envVal != "" {
+		if ttl, err := strconv.Atoi(envVal); err == nil {
+			config.Auth.jwtAccessTTL = ttl
+		}
+	}
+
+	if envVal := os.Getenv("OIDC_ISSUER_URL"); envVal != "" {
+		config.OIDC.issuerURL = envVal
+	}
+	if envVal := os.Getenv("OIDC_CLIENT_ID"); envVal != "" {
+		config.OIDC.clientID = envVal
+	}
+	if envVal := os.Getenv("OIDC_CLIENT_SECRET"); envVal != "" {
+		config.OIDC.clientSecret = envVal
+	}
+	if envVal := os.Getenv("OIDC_REDIRECT_URL"); envVal != "" {
+		config.OIDC.redirectURL = envVal
+	}
+	if envVal := os.Getenv("OIDC_SCOPES"); envVal != "" {
+		config.OIDC.scopes = strings.Fields(strings.ReplaceAll(envVal, ",", " "))
+	}
+	if envVal := os.Getenv("OIDC_DEFAULT_ROLE"); envVal != "" {
+		config.OIDC.defaultRole = envVal
+	}
+
+	if envVal := os.Getenv("LDAP_MODE"); envVal != "" {
+		config.LDAP.mode = envVal
+	}
+	if envVal := os.Getenv("LDAP_URL"); envVal != "" {
+		config.LDAP.url = envVal
+	}
+	if envVal := os.Getenv("LDAP_START_TLS"); envVal != "" {
+		if startTLS, err := strconv.ParseBool(envVal); err == nil {
+			config.LDAP.startTLS = startTLS
+		}
+	}
+	if envVal := os.Getenv("LDAP_INSECURE_SKIP_VERIFY"); envVal != "" {
+		if skip, err := strconv.ParseBool(envVal); err == nil {
+			config.LDAP.insecureSkipVerify = skip
+		}
+	}
+	if envVal := os.Getenv("LDAP_BIND_DN"); envVal != "" {
+		config.LDAP.bindDN = envVal
+	}
+	if envVal := os.Getenv("LDAP_BIND_PASSWORD"); envVal != "" {
+		config.LDAP.bindPassword = envVal
+	}
+	if envVal := os.Getenv("LDAP_BASE_DN"); envVal != "" {
+		config.LDAP.baseDN = envVal
+	}
+	if envVal := os.Getenv("LDAP_USER_FILTER"); envVal != "" {
+		config.LDAP.userFilter = envVal
+	}
+	if envVal := os.Getenv("LDAP_LOGIN_ATTRIBUTE"); envVal != "" {
+		config.LDAP.loginAttribute = envVal
+	}
+	if envVal := os.Getenv("LDAP_GROUP_ATTRIBUTE"); envVal != "" {
+		config.LDAP.groupAttribute = envVal
+	}
+	if envVal := os.Getenv("LDAP_DEFAULT_ROLE"); envVal != "" {
+		config.LDAP.defaultRole = envVal
+	}
+
+	if envVal := os.Getenv("TOTP_ISSUER"); envVal != "" {
+		config.MFA.totpIssuer = envVal
+	}
+
+	if envVal := os.Getenv("AUTH_MAX_FAILURES"); envVal != "" {
+		if n, err := strconv.Atoi(envVal); err == nil {
+			config.Lockout.maxFailures = n
+		}
+	}
+	if envVal := os.Getenv("AUTH_IP_MAX_FAILURES"); envVal != "" {
+		if n, err := strconv.Atoi(envVal); err == nil {
+			config.Lockout.ipMaxFailures = n
+		}
+	}
+	if envVal := os.Getenv("AUTH_BACKOFF_BASE"); envVal != "" {
+		if base, err := strconv.Atoi(envVal); err == nil {
+			config.Lockout.backoffBase = base
+		}
+	}
+	if envVal := os.Getenv("AUTH_LOCKOUT_MAX"); envVal != "" {
+		if lockout, err := strconv.Atoi(envVal); err == nil {
+			config.Lockout.maxLockout = lockout
+		}
+	}
+	if envVal := os.Getenv("AUTH_FAILURE_WINDOW"); envVal != "" {
+		if window, err := strconv.Atoi(envVal); err == nil {
+			config.Lockout.failureWindow = window
+		}
+	}
+	if envVal := os.Getenv("AUTH_LOCKOUT_CAPACITY"); envVal != "" {
+		if capacity, err := strconv.Atoi(envVal); err == nil {
+			config.Lockout.capacity = capacity
+		}
+	}
+
+	if envVal := os.Getenv("SHARE_LINK_SECRET"); envVal != "" {
+		config.ShareLink.secret = envVal
+	}
+	if envVal := os.Getenv("SHARE_LINK_DEFAULT_TTL"); envVal != "" {
+		if ttl, err := strconv.Atoi(envVal); err == nil {
+			config.ShareLink.defaultTTL = ttl
+		}
+	}
+	if envVal := os.Getenv("SHARE_LINK_MAX_TTL"); envVal != "" {
+		if ttl, err := strconv.Atoi(envVal); err == nil {
+			config.ShareLink.maxTTL = ttl
+		}
+	}
+	if envVal := os.Getenv("SHARE_LINK_PASSWORD_RATE"); envVal != "" {
+		if n, err := strconv.Atoi(envVal); err == nil {
+			config.ShareLink.passwordRate = n
+		}
+	}
+	if envVal := os.Getenv("SHARE_LINK_PASSWORD_BURST"); envVal != "" {
+		if n, err := strconv.Atoi(envVal); err == nil {
+			config.ShareLink.passwordBurst = n
+		}
+	}
+
+	if envVal := os.Getenv("PUBLIC_ACCESS"); envVal != "" {
+		if enabled, err := strconv.ParseBool(envVal); err == nil {
+			config.Public.enabled = enabled
+		}
+	}
+	if envVal := os.Getenv("PUBLIC_RATE_LIMIT"); envVal != "" {
+		if n, err := strconv.Atoi(envVal); err == nil {
+			config.Public.rateLimit = n
+		}
+	}
+	if envVal := os.Getenv("PUBLIC_RATE_BURST"); envVal != "" {
+		if n, err := strconv.Atoi(envVal); err == nil {
+			config.Public.rateBurst = n
+		}
+	}
+	if envVal := os.Getenv("PUBLIC_RATE_CAPACITY"); envVal != "" {
+		if capacity, err := strconv.Atoi(envVal); err == nil {
+			config.Public.capacity = capacity
+		}
+	}
+
+	if envVal := os.Getenv("SEARCH_LANGUAGE"); envVal != "" {
+		config.Search.language = envVal
+	}
+
+	if envVal := os.Getenv("EXTRACT_WORKERS"); envVal != "" {
+		if n, err := strconv.Atoi(envVal); err == nil {
+			config.Extraction.workers = n
+		}
+	}
+	if envVal := os.Getenv("EXTRACT_QUEUE_SIZE"); envVal != "" {
+		if n, err := strconv.Atoi(envVal); err == nil {
+			config.Extraction.queueSize = n
+		}
+	}
+	if envVal := os.Getenv("EXTRACT_MAX_SIZE"); envVal != "" {
+		if size, err := strconv.ParseInt(envVal, 10, 64); err == nil {
+			config.Extraction.maxSize = size
+		}
+	}
+	if envVal := os.Getenv("EXTRACT_SWEEP_INTERVAL"); envVal != "" {
+		if interval, err := strconv.Atoi(envVal); err == nil {
+			config.Extraction.sweepInterval = interval
+		}
+	}
 
 	if envVal := os.Getenv("ADMIN_TOKEN"); envVal != "" {
 		config.Admin.token = envVal
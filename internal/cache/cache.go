@@ -70,6 +70,18 @@ func (c *LFUCache) DeletePrefix(ctx context.Context, prefix string) {
 	}
 }
 
+func (c *LFUCache) Keys(ctx context.Context, prefix string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var keys []string
+	for k := range c.items {
+		if startsWith(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
 func (c *LFUCache) removeLFU() {
 	var lfuKey string
 	var minCount = int(^uint(0) >> 1) 
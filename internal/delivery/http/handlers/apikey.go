@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	models "docs_storage/internal/models"
+	utils "docs_storage/internal/utils"
+	"docs_storage/pkg/logger"
+)
+
+type apiKeyService interface {
+	Create(ctx context.Context, token, name string, scopes []string, expiresAt *time.Time) (*models.APIKey, string, error)
+	List(ctx context.Context, token string) ([]models.APIKey, error)
+	Revoke(ctx context.Context, token string, id int) error
+}
+
+type APIKeyHandler struct {
+	svc    apiKeyService
+	logger *logger.Logger
+}
+
+func NewAPIKeyHandler(svc apiKeyService, log *logger.Logger) *APIKeyHandler {
+	return &APIKeyHandler{svc: svc, logger: log}
+}
+
+func (h *APIKeyHandler) HandleCreateKey(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("create api key attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	var input struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.logger.Error.Printf("failed to decode api key input: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+
+	k, key, err := h.svc.Create(r.Context(), token, input.Name, input.Scopes, input.ExpiresAt)
+	if err != nil {
+		h.logger.Error.Printf("failed to create api key %q: %v", input.Name, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("api key %d (%s) created for user %d", k.ID, k.Prefix, k.UserID)
+	utils.WriteJSON(w, http.StatusCreated, utils.APIKeyCreated(*k, key))
+}
+
+func (h *APIKeyHandler) HandleListKeys(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("list api keys attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	keys, err := h.svc.List(r.Context(), token)
+	if err != nil {
+		h.logger.Error.Printf("failed to list api keys: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.APIKeysList(keys))
+}
+
+func (h *APIKeyHandler) HandleRevokeKey(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("revoke api key attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid key id"))
+		return
+	}
+
+	if err := h.svc.Revoke(r.Context(), token, id); err != nil {
+		h.logger.Error.Printf("failed to revoke api key %d: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("api key %d revoked", id)
+	utils.WriteJSON(w, http.StatusOK, utils.DeleteResp(strconv.Itoa(id)))
+}
@@ -3,28 +3,38 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 
+	"docs_storage/internal/service"
 	"docs_storage/internal/utils"
 	"docs_storage/pkg/logger"
 )
 
 type authService interface {
-	Register(ctx context.Context, token, login, pswd string) error
-	Auth(ctx context.Context, login, pswd string) (string, error)
+	Register(ctx context.Context, token, login, pswd, role string) error
+	Auth(ctx context.Context, login, pswd, ip string) (*service.AuthTokens, error)
+	Refresh(ctx context.Context, refreshToken string) (*service.AuthTokens, error)
+	CompleteMFA(ctx context.Context, mfaToken, code string) (*service.AuthTokens, error)
 	Logout(ctx context.Context, token string) error
 }
 
 type AuthHandler struct {
-	svc    authService
-	logger *logger.Logger
+	svc        authService
+	logger     *logger.Logger
+	trustProxy bool
 }
 
-func NewAuthHandler(svc authService, log *logger.Logger) *AuthHandler {
+// NewAuthHandler takes client addresses for login throttling from
+// X-Forwarded-For if trustProxy is set, i.e. when running behind a proxy.
+func NewAuthHandler(svc authService, log *logger.Logger, trustProxy bool) *AuthHandler {
 	return &AuthHandler{
-		svc:    svc,
-		logger: log,
+		svc:        svc,
+		logger:     log,
+		trustProxy: trustProxy,
 	}
 }
 
@@ -33,6 +43,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		Token string `json:"token"`
 		Login string `json:"login"`
 		Pswd  string `json:"pswd"`
+		Role  string `json:"role"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		h.logger.Error.Printf("failed to decode register input: %v", err)
@@ -40,7 +51,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.svc.Register(r.Context(), input.Token, input.Login, input.Pswd); err != nil {
+	if err := h.svc.Register(r.Context(), input.Token, input.Login, input.Pswd, input.Role); err != nil {
 		h.logger.Error.Printf("register failed for login %s: %v", input.Login, err)
 		utils.WriteJSON(w, http.StatusForbidden, utils.ErrorResp(err.Error()))
 		return
@@ -61,15 +72,70 @@ func (h *AuthHandler) Auth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := h.svc.Auth(r.Context(), input.Login, input.Pswd)
+	ip := utils.ClientIP(r, h.trustProxy)
+	tokens, err := h.svc.Auth(r.Context(), input.Login, input.Pswd, ip)
 	if err != nil {
-		h.logger.Error.Printf("auth failed for login %s: %v", input.Login, err)
-		utils.WriteJSON(w, http.StatusUnauthorized, utils.ErrorResp(err.Error()))
+		h.logger.Error.Printf("auth failed for login %s from %s: %v", input.Login, ip, err)
+		var locked *service.LockedError
+		if errors.As(err, &locked) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		}
+		utils.WriteJSON(w, errorStatus(err, http.StatusUnauthorized), utils.ErrorResp(err.Error()))
 		return
 	}
 
 	h.logger.Info.Printf("user authenticated: %s", input.Login)
-	utils.WriteJSON(w, http.StatusOK, utils.AuthResp(token))
+	utils.WriteJSON(w, http.StatusOK, authResp(tokens))
+}
+
+func (h *AuthHandler) MFA(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.logger.Error.Printf("failed to decode mfa input: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+	if input.MFAToken == "" || input.Code == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("mfa_token and code required"))
+		return
+	}
+
+	tokens, err := h.svc.CompleteMFA(r.Context(), input.MFAToken, input.Code)
+	if err != nil {
+		h.logger.Error.Printf("mfa verification failed: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Print("user completed mfa")
+	utils.WriteJSON(w, http.StatusOK, authResp(tokens))
+}
+
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.logger.Error.Printf("failed to decode refresh input: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+	if input.RefreshToken == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("refresh_token required"))
+		return
+	}
+
+	tokens, err := h.svc.Refresh(r.Context(), input.RefreshToken)
+	if err != nil {
+		h.logger.Error.Printf("token refresh failed: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, authResp(tokens))
 }
 
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
@@ -89,3 +155,10 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info.Printf("user logged out, token: %s", token)
 	utils.WriteJSON(w, http.StatusOK, utils.LogoutResp(token))
 }
+
+func authResp(t *service.AuthTokens) map[string]any {
+	if t.MFAToken != "" {
+		return utils.MFARequiredResp(t.MFAToken, int(t.ExpiresIn.Seconds()))
+	}
+	return utils.AuthResp(t.Token, t.RefreshToken, int(t.ExpiresIn.Seconds()))
+}
@@ -1,33 +1,173 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	models "docs_storage/internal/models"
 	utils "docs_storage/internal/utils"
 	"docs_storage/pkg/logger"
+	"docs_storage/pkg/ratelimit"
 )
 
+const maxFormFieldSize = 10 << 20
+
+var errFieldTooLarge = errors.New("form field too large")
+
 type docsService interface {
-	Create(ctx context.Context, meta *models.Document, fileName string, fileData []byte, jsonData []byte, token string) (*models.Document, error)
-	List(ctx context.Context, token, login, key, value string, limit int) ([]models.Document, error)
+	Create(ctx context.Context, meta *models.Document, file io.Reader, jsonData []byte, token string) (*models.Document, error)
+	List(ctx context.Context, token string, q models.DocumentQuery) (*models.DocumentPage, error)
 	GetByID(ctx context.Context, id, token string) (*models.Document, error)
 	Delete(ctx context.Context, id, token string) error
+	Update(ctx context.Context, id string, meta *models.Document, file io.Reader, jsonData []byte, token string) (*models.Document, error)
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	ListVersions(ctx context.Context, id, token string) ([]models.DocumentVersion, error)
+	GetVersion(ctx context.Context, id string, version int, token string) (*models.DocumentVersion, error)
+	Restore(ctx context.Context, id string, version int, token string) (*models.Document, error)
+	Share(ctx context.Context, id, token, grantee string, perms []string) (*models.Document, error)
+	ListGrants(ctx context.Context, id, token string) ([]models.ACLEntry, error)
+	SetGrant(ctx context.Context, id, token, grantee string, perms []string) (*models.Document, error)
+	RevokeGrant(ctx context.Context, id, token, grantee string) (*models.Document, error)
+	UpdateMeta(ctx context.Context, id, token string, name *string, public *bool) (*models.Document, error)
+	ListPublic(ctx context.Context, q models.DocumentQuery) (*models.DocumentPage, error)
+	GetPublic(ctx context.Context, id string) (*models.Document, error)
+	Search(ctx context.Context, token, text string, q models.DocumentQuery) ([]models.SearchResult, error)
+	SearchPublic(ctx context.Context, text string, q models.DocumentQuery) ([]models.SearchResult, error)
 }
 
 type DocsHandler struct {
-	svc    docsService
-	logger *logger.Logger
+	svc           docsService
+	logger        *logger.Logger
+	maxUploadSize int64
+	// anonymous rate-limits requests without a session per client address.
+	// When it is nil such requests are refused.
+	anonymous  *ratelimit.Limiter
+	trustProxy bool
+}
+
+func NewDocsHandler(svc docsService, log *logger.Logger, maxUploadSize int64, anonymous *ratelimit.Limiter, trustProxy bool) *DocsHandler {
+	return &DocsHandler{svc: svc, logger: log, maxUploadSize: maxUploadSize, anonymous: anonymous, trustProxy: trustProxy}
+}
+
+// allowAnonymous reports whether a request without a session may go on to
+// see public documents, and answers it if not.
+func (h *DocsHandler) allowAnonymous(w http.ResponseWriter, r *http.Request) bool {
+	if h.anonymous == nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return false
+	}
+
+	ip := utils.ClientIP(r, h.trustProxy)
+	if ok, wait := h.anonymous.Allow(ip); !ok {
+		h.logger.Error.Printf("anonymous rate limit exceeded by %s", ip)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		utils.WriteJSON(w, http.StatusTooManyRequests, utils.ErrorResp("rate limit exceeded"))
+		return false
+	}
+
+	// Only public documents are served here, so any page may embed them.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	return true
 }
 
-func NewDocsHandler(svc docsService, log *logger.Logger) *DocsHandler {
-	return &DocsHandler{svc: svc, logger: log}
+// uploadForm holds the multipart fields of an upload. The file part is left
+// unread so it can be streamed into storage, which is why it has to be the
+// last part of the request body: anything sent after it is ignored.
+type uploadForm struct {
+	meta     []byte
+	jsonData []byte
+	file     io.Reader
+}
+
+func (h *DocsHandler) readUploadForm(w http.ResponseWriter, r *http.Request) (*uploadForm, error) {
+	if h.maxUploadSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadSize)
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	form := &uploadForm{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch part.FormName() {
+		case "meta":
+			if form.meta, err = readFormField(part); err != nil {
+				return nil, err
+			}
+		case "json":
+			if form.jsonData, err = readFormField(part); err != nil {
+				return nil, err
+			}
+		case "file":
+			form.file = part
+			return form, nil
+		}
+	}
+}
+
+// readFormField returns nil for a blank field, which counts as not sent.
+func readFormField(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxFormFieldSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFormFieldSize {
+		return nil, errFieldTooLarge
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+	return data, nil
+}
+
+func isTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr) || errors.Is(err, errFieldTooLarge)
+}
+
+func (h *DocsHandler) serveFile(w http.ResponseWriter, r *http.Request, key, name, mime string, modTime time.Time) {
+	serveFile(w, r, h.svc, h.logger, key, name, mime, modTime)
+}
+
+type fileOpener interface {
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+}
+
+// serveFile streams a stored file with range and conditional request
+// support. Every download, with or without a session, goes through it.
+func serveFile(w http.ResponseWriter, r *http.Request, files fileOpener, log *logger.Logger, key, name, mime string, modTime time.Time) {
+	content, err := files.Open(r.Context(), key)
+	if err != nil {
+		log.Error.Printf("failed to open file %s: %v", key, err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.ErrorResp("cannot open file"))
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", mime)
+	http.ServeContent(w, r, name, modTime, content)
 }
 
 func (h *DocsHandler) HandleUploadDoc(w http.ResponseWriter, r *http.Request) {
@@ -38,49 +178,43 @@ func (h *DocsHandler) HandleUploadDoc(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseMultipartForm(10 << 20); err != nil {
+	form, err := h.readUploadForm(w, r)
+	if err != nil {
 		h.logger.Error.Printf("failed to parse multipart form: %v", err)
+		if isTooLarge(err) {
+			utils.WriteJSON(w, http.StatusRequestEntityTooLarge, utils.ErrorResp("request body too large"))
+			return
+		}
 		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("cannot parse form"))
 		return
 	}
 
-	metaPart := r.FormValue("meta")
-	if metaPart == "" {
+	if len(form.meta) == 0 {
 		h.logger.Error.Print("upload attempt without meta")
 		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("meta is required"))
 		return
 	}
 
 	var meta models.Document
-	if err := json.Unmarshal([]byte(metaPart), &meta); err != nil {
+	if err := json.Unmarshal(form.meta, &meta); err != nil {
 		h.logger.Error.Printf("invalid meta json: %v", err)
 		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid meta json"))
 		return
 	}
 
-	var jsonData []byte
-	if jsonPart := r.FormValue("json"); jsonPart != "" {
-		jsonData = []byte(jsonPart)
-	}
-
-	var fileName string
-	var fileData []byte
-	if meta.File {
-		file, header, err := r.FormFile("file")
-		if err != nil {
-			h.logger.Error.Print("file is required but missing")
-			utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("file is required"))
-			return
-		}
-		defer file.Close()
-
-		fileName = header.Filename
-		fileData, _ = io.ReadAll(file)
+	if meta.File && form.file == nil {
+		h.logger.Error.Print("file is required but missing")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("file is required"))
+		return
 	}
 
-	doc, err := h.svc.Create(r.Context(), &meta, fileName, fileData, jsonData, token)
+	doc, err := h.svc.Create(r.Context(), &meta, form.file, form.jsonData, token)
 	if err != nil {
 		h.logger.Error.Printf("failed to create document: %v", err)
+		if isTooLarge(err) {
+			utils.WriteJSON(w, http.StatusRequestEntityTooLarge, utils.ErrorResp("request body too large"))
+			return
+		}
 		utils.WriteJSON(w, http.StatusInternalServerError, utils.ErrorResp("cannot create document"))
 		return
 	}
@@ -92,55 +226,144 @@ func (h *DocsHandler) HandleUploadDoc(w http.ResponseWriter, r *http.Request) {
 func (h *DocsHandler) HandleListDocs(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	token := utils.ExtractToken(r)
-	if token == "" {
+	if token == "" && !h.allowAnonymous(w, r) {
 		h.logger.Error.Print("list attempt without token")
-		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
 		return
 	}
 
-	login := r.URL.Query().Get("login")
-	key := r.URL.Query().Get("key")
-	value := r.URL.Query().Get("value")
+	q, err := listQuery(r.URL.Query())
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+
+	var page *models.DocumentPage
+	if token == "" {
+		page, err = h.svc.ListPublic(ctx, q)
+	} else {
+		page, err = h.svc.List(ctx, token, q)
+	}
+	if err != nil {
+		h.logger.Error.Printf("failed to list documents: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusForbidden), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("documents listed by token %s, count: %d", token, len(page.Docs))
+	utils.WriteJSON(w, http.StatusOK, utils.DocsList(*page))
+}
+
+var errFilterSyntax = errors.New("filter must be field:op:value")
 
-	limit := 0
-	if l := r.URL.Query().Get("limit"); l != "" {
+// listQuery reads the listing parameters shared by listing and search.
+func listQuery(query url.Values) (models.DocumentQuery, error) {
+	q := models.DocumentQuery{
+		Login:  query.Get("login"),
+		Key:    query.Get("key"),
+		Value:  query.Get("value"),
+		Sort:   query.Get("sort"),
+		Cursor: query.Get("cursor"),
+	}
+	if l := query.Get("limit"); l != "" {
 		if n, err := strconv.Atoi(l); err == nil {
-			limit = n
+			q.Limit = n
 		}
 	}
+	if t := query.Get("total"); t != "" {
+		q.WithTotal, _ = strconv.ParseBool(t)
+	}
+	for _, f := range query["filter"] {
+		parts := strings.SplitN(f, ":", 3)
+		if len(parts) != 3 {
+			return q, errFilterSyntax
+		}
+		q.Filters = append(q.Filters, models.DocumentCondition{Field: parts[0], Op: parts[1], Value: parts[2]})
+	}
+	q.Filters = append(q.Filters, jsonFilters(query)...)
+	return q, nil
+}
 
-	docs, err := h.svc.List(ctx, token, login, key, value, limit)
+func (h *DocsHandler) HandleSearchDocs(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	token := utils.ExtractToken(r)
+	if token == "" && !h.allowAnonymous(w, r) {
+		h.logger.Error.Print("search attempt without token")
+		return
+	}
+
+	text := r.URL.Query().Get("q")
+	q, err := listQuery(r.URL.Query())
 	if err != nil {
-		h.logger.Error.Printf("failed to list documents: %v", err)
-		utils.WriteJSON(w, http.StatusForbidden, utils.ErrorResp(err.Error()))
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+
+	var results []models.SearchResult
+	if token == "" {
+		results, err = h.svc.SearchPublic(ctx, text, q)
+	} else {
+		results, err = h.svc.Search(ctx, token, text, q)
+	}
+	if err != nil {
+		h.logger.Error.Printf("failed to search documents for %q: %v", text, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
 		return
 	}
 
-	h.logger.Info.Printf("documents listed by token %s, count: %d", token, len(docs))
-	utils.WriteJSON(w, http.StatusOK, utils.DocsList(docs))
+	h.logger.Info.Printf("documents searched by token %s, count: %d", token, len(results))
+	utils.WriteJSON(w, http.StatusOK, utils.SearchResults(results))
+}
+
+// jsonFilters reads the json.<path>[:op]=value parameters, which filter on
+// fields inside json_data. Without an operator they mean equality.
+func jsonFilters(query url.Values) []models.DocumentCondition {
+	var params []string
+	for param := range query {
+		if strings.HasPrefix(param, models.JSONFieldPrefix) {
+			params = append(params, param)
+		}
+	}
+	// Sorted so that the same filters always make the same query.
+	sort.Strings(params)
+
+	var conds []models.DocumentCondition
+	for _, param := range params {
+		field, op, ok := strings.Cut(param, ":")
+		if !ok {
+			op = "eq"
+		}
+		for _, value := range query[param] {
+			conds = append(conds, models.DocumentCondition{Field: field, Op: op, Value: value})
+		}
+	}
+	return conds
 }
 
 func (h *DocsHandler) HandleGetDoc(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	id := mux.Vars(r)["id"]
 	token := utils.ExtractToken(r)
-	if token == "" {
+	if token == "" && !h.allowAnonymous(w, r) {
 		h.logger.Error.Print("get document attempt without token")
-		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
 		return
 	}
 
-	doc, err := h.svc.GetByID(ctx, id, token)
+	var doc *models.Document
+	var err error
+	if token == "" {
+		doc, err = h.svc.GetPublic(ctx, id)
+	} else {
+		doc, err = h.svc.GetByID(ctx, id, token)
+	}
 	if err != nil {
 		h.logger.Error.Printf("failed to get document %s: %v", id, err)
-		utils.WriteJSON(w, http.StatusForbidden, utils.ErrorResp(err.Error()))
+		utils.WriteJSON(w, errorStatus(err, http.StatusForbidden), utils.ErrorResp(err.Error()))
 		return
 	}
 
 	h.logger.Info.Printf("document retrieved: %s by token %s", id, token)
 	if doc.File {
-		w.Header().Set("Content-Type", doc.Mime)
-		http.ServeFile(w, r, doc.FilePath)
+		h.serveFile(w, r, doc.FilePath, doc.Name, doc.Mime, doc.CreatedAt)
 		return
 	}
 
@@ -159,10 +382,283 @@ func (h *DocsHandler) HandleDeleteDoc(w http.ResponseWriter, r *http.Request) {
 
 	if err := h.svc.Delete(ctx, id, token); err != nil {
 		h.logger.Error.Printf("failed to delete document %s: %v", id, err)
-		utils.WriteJSON(w, http.StatusForbidden, utils.ErrorResp(err.Error()))
+		utils.WriteJSON(w, errorStatus(err, http.StatusForbidden), utils.ErrorResp(err.Error()))
 		return
 	}
 
 	h.logger.Info.Printf("document deleted: %s by token %s", id, token)
 	utils.WriteJSON(w, http.StatusOK, utils.DeleteResp(id))
 }
+
+
+func (h *DocsHandler) HandleShareDoc(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("share attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	var input struct {
+		Grantee     string   `json:"grantee"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.logger.Error.Printf("failed to decode share input: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+
+	doc, err := h.svc.Share(ctx, id, token, input.Grantee, input.Permissions)
+	if err != nil {
+		h.logger.Error.Printf("failed to share document %s with %s: %v", id, input.Grantee, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusForbidden), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("document %s shared with %s: %v", id, input.Grantee, input.Permissions)
+	utils.WriteJSON(w, http.StatusOK, utils.DocDetail(*doc))
+}
+
+func (h *DocsHandler) HandleListGrants(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("list grants attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	acl, err := h.svc.ListGrants(ctx, id, token)
+	if err != nil {
+		h.logger.Error.Printf("failed to list grants of document %s: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusForbidden), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.GrantsList(acl))
+}
+
+func (h *DocsHandler) HandleSetGrant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("set grant attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	var input struct {
+		Grantee     string   `json:"grantee"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.logger.Error.Printf("failed to decode grant input: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+
+	doc, err := h.svc.SetGrant(ctx, id, token, input.Grantee, input.Permissions)
+	if err != nil {
+		h.logger.Error.Printf("failed to grant %v on document %s to %s: %v", input.Permissions, id, input.Grantee, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusForbidden), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("document %s granted to %s: %v", id, input.Grantee, input.Permissions)
+	utils.WriteJSON(w, http.StatusOK, utils.DocDetail(*doc))
+}
+
+// HandleRevokeGrant removes the entry of the grantee given as ?grantee=.
+func (h *DocsHandler) HandleRevokeGrant(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("revoke grant attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	grantee := r.URL.Query().Get("grantee")
+	if grantee == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("grantee required"))
+		return
+	}
+
+	doc, err := h.svc.RevokeGrant(ctx, id, token, grantee)
+	if err != nil {
+		h.logger.Error.Printf("failed to revoke grant on document %s from %s: %v", id, grantee, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusForbidden), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("grant on document %s revoked from %s", id, grantee)
+	utils.WriteJSON(w, http.StatusOK, utils.DocDetail(*doc))
+}
+
+func (h *DocsHandler) HandlePatchDoc(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("patch attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	var input struct {
+		Name   *string `json:"name"`
+		Public *bool   `json:"public"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.logger.Error.Printf("failed to decode patch input: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+
+	doc, err := h.svc.UpdateMeta(ctx, id, token, input.Name, input.Public)
+	if err != nil {
+		h.logger.Error.Printf("failed to update metadata of document %s: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusForbidden), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("metadata of document %s updated", id)
+	utils.WriteJSON(w, http.StatusOK, utils.DocDetail(*doc))
+}
+
+func (h *DocsHandler) HandleUpdateDoc(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("update attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	form, err := h.readUploadForm(w, r)
+	if err != nil {
+		h.logger.Error.Printf("failed to parse multipart form: %v", err)
+		if isTooLarge(err) {
+			utils.WriteJSON(w, http.StatusRequestEntityTooLarge, utils.ErrorResp("request body too large"))
+			return
+		}
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("cannot parse form"))
+		return
+	}
+
+	var meta models.Document
+	if len(form.meta) > 0 {
+		if err := json.Unmarshal(form.meta, &meta); err != nil {
+			h.logger.Error.Printf("invalid meta json: %v", err)
+			utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid meta json"))
+			return
+		}
+	}
+
+	if form.file == nil && form.jsonData == nil {
+		h.logger.Error.Print("update attempt without file and json")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("file or json is required"))
+		return
+	}
+
+	doc, err := h.svc.Update(ctx, id, &meta, form.file, form.jsonData, token)
+	if err != nil {
+		h.logger.Error.Printf("failed to update document %s: %v", id, err)
+		if isTooLarge(err) {
+			utils.WriteJSON(w, http.StatusRequestEntityTooLarge, utils.ErrorResp("request body too large"))
+			return
+		}
+		utils.WriteJSON(w, errorStatus(err, http.StatusForbidden), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("document updated: %s to version %d by token %s", id, doc.Version, token)
+	utils.WriteJSON(w, http.StatusOK, utils.DocDetail(*doc))
+}
+
+func (h *DocsHandler) HandleListVersions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("list versions attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	versions, err := h.svc.ListVersions(ctx, id, token)
+	if err != nil {
+		h.logger.Error.Printf("failed to list versions of document %s: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusForbidden), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("versions of document %s listed by token %s, count: %d", id, token, len(versions))
+	utils.WriteJSON(w, http.StatusOK, utils.VersionsList(versions))
+}
+
+func (h *DocsHandler) HandleGetVersion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("get version attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	version, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid version"))
+		return
+	}
+
+	v, err := h.svc.GetVersion(ctx, id, version, token)
+	if err != nil {
+		h.logger.Error.Printf("failed to get version %d of document %s: %v", version, id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusForbidden), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("version %d of document %s retrieved by token %s", version, id, token)
+	if v.File {
+		h.serveFile(w, r, v.FilePath, id, v.Mime, v.CreatedAt)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.VersionDetail(*v))
+}
+
+func (h *DocsHandler) HandleRestoreVersion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("restore attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	version, err := strconv.Atoi(mux.Vars(r)["version"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid version"))
+		return
+	}
+
+	doc, err := h.svc.Restore(ctx, id, version, token)
+	if err != nil {
+		h.logger.Error.Printf("failed to restore version %d of document %s: %v", version, id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusForbidden), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("document %s restored from version %d by token %s", id, version, token)
+	utils.WriteJSON(w, http.StatusOK, utils.DocDetail(*doc))
+}
\ No newline at end of file
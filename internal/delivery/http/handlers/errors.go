@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	service "docs_storage/internal/service"
+)
+
+// errorStatus maps service errors to HTTP status codes. Errors it does not
+// know about get the fallback status.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidToken), errors.Is(err, service.ErrSessionExpired),
+		errors.Is(err, service.ErrAPIKeyExpired),
+		errors.Is(err, service.ErrMFAChallenge), errors.Is(err, service.ErrInvalidMFACode):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrTOTPAlreadyEnabled), errors.Is(err, service.ErrGroupExists),
+		errors.Is(err, service.ErrNameTaken), errors.Is(err, service.ErrConflict),
+		errors.Is(err, service.ErrOIDCAccountExists), errors.Is(err, service.ErrOIDCIdentityTaken):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrRefreshUnsupported), errors.Is(err, service.ErrOIDCState),
+		errors.Is(err, service.ErrOIDCNoSubject):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidCredentials), errors.Is(err, service.ErrShareLinkPassword):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrShareLinkExpired):
+		return http.StatusGone
+	case errors.Is(err, service.ErrTooManyAttempts):
+		return http.StatusTooManyRequests
+	case errors.Is(err, service.ErrAccountDisabled), errors.Is(err, service.ErrAccountSource):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrUploadOffset), errors.Is(err, service.ErrUploadLocked):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrUploadTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, service.ErrInvalidUploadMeta),
+		errors.Is(err, service.ErrEmptyPassword),
+		errors.Is(err, service.ErrSelfAction),
+		errors.Is(err, service.ErrInvalidScope),
+		errors.Is(err, service.ErrInvalidKeyName),
+		errors.Is(err, service.ErrInvalidKeyExpiry),
+		errors.Is(err, service.ErrTOTPNotEnrolled),
+		errors.Is(err, service.ErrInvalidLockoutKind),
+		errors.Is(err, service.ErrInvalidGroupName),
+		errors.Is(err, service.ErrEmptyUpdate),
+		errors.Is(err, service.ErrInvalidGrant),
+		errors.Is(err, service.ErrInvalidDocumentName),
+		errors.Is(err, service.ErrInvalidShareLink),
+		errors.Is(err, service.ErrInvalidListing),
+		errors.Is(err, service.ErrEmptySearch):
+		return http.StatusBadRequest
+	default:
+		return fallback
+	}
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	models "docs_storage/internal/models"
+	utils "docs_storage/internal/utils"
+	"docs_storage/pkg/logger"
+)
+
+type groupService interface {
+	List(ctx context.Context, token string) ([]models.Group, error)
+	Get(ctx context.Context, token string, id int) (*models.Group, error)
+	Create(ctx context.Context, token, name, description string) (*models.Group, error)
+	Update(ctx context.Context, token string, id int, name, description *string) (*models.Group, error)
+	Delete(ctx context.Context, token string, id int) error
+	AddMember(ctx context.Context, token string, groupID, userID int) error
+	RemoveMember(ctx context.Context, token string, groupID, userID int) error
+}
+
+type GroupHandler struct {
+	svc    groupService
+	logger *logger.Logger
+}
+
+func NewGroupHandler(svc groupService, log *logger.Logger) *GroupHandler {
+	return &GroupHandler{svc: svc, logger: log}
+}
+
+func (h *GroupHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("list groups attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	groups, err := h.svc.List(r.Context(), token)
+	if err != nil {
+		h.logger.Error.Printf("failed to list groups: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.GroupsList(groups))
+}
+
+func (h *GroupHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("get group attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid group id"))
+		return
+	}
+
+	g, err := h.svc.Get(r.Context(), token, id)
+	if err != nil {
+		h.logger.Error.Printf("failed to get group %d: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.GroupDetail(*g))
+}
+
+func (h *GroupHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("create group attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	var input struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.logger.Error.Printf("failed to decode group input: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+
+	g, err := h.svc.Create(r.Context(), token, input.Name, input.Description)
+	if err != nil {
+		h.logger.Error.Printf("failed to create group %q: %v", input.Name, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("group %d %q created", g.ID, g.Name)
+	utils.WriteJSON(w, http.StatusCreated, utils.GroupDetail(*g))
+}
+
+func (h *GroupHandler) HandleUpdate(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("update group attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid group id"))
+		return
+	}
+
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.logger.Error.Printf("failed to decode group input: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+
+	g, err := h.svc.Update(r.Context(), token, id, input.Name, input.Description)
+	if err != nil {
+		h.logger.Error.Printf("failed to update group %d: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("group %d updated", id)
+	utils.WriteJSON(w, http.StatusOK, utils.GroupDetail(*g))
+}
+
+func (h *GroupHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("delete group attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid group id"))
+		return
+	}
+
+	if err := h.svc.Delete(r.Context(), token, id); err != nil {
+		h.logger.Error.Printf("failed to delete group %d: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("group %d deleted", id)
+	utils.WriteJSON(w, http.StatusOK, utils.DeleteResp(strconv.Itoa(id)))
+}
+
+func (h *GroupHandler) HandleAddMember(w http.ResponseWriter, r *http.Request) {
+	h.setMember(w, r, true)
+}
+
+func (h *GroupHandler) HandleRemoveMember(w http.ResponseWriter, r *http.Request) {
+	h.setMember(w, r, false)
+}
+
+func (h *GroupHandler) setMember(w http.ResponseWriter, r *http.Request, member bool) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("group membership change attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	groupID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid group id"))
+		return
+	}
+	userID, err := strconv.Atoi(mux.Vars(r)["user_id"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid user id"))
+		return
+	}
+
+	if member {
+		err = h.svc.AddMember(r.Context(), token, groupID, userID)
+	} else {
+		err = h.svc.RemoveMember(r.Context(), token, groupID, userID)
+	}
+	if err != nil {
+		h.logger.Error.Printf("failed to set member=%t of user %d in group %d: %v", member, userID, groupID, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("user %d member=%t of group %d", userID, member, groupID)
+	utils.WriteJSON(w, http.StatusOK, utils.GroupMemberResp(groupID, userID, member))
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	models "docs_storage/internal/models"
+	utils "docs_storage/internal/utils"
+	"docs_storage/pkg/logger"
+)
+
+type lockoutService interface {
+	List(ctx context.Context, token string) ([]models.Lockout, error)
+	Clear(ctx context.Context, token, kind, value string) error
+}
+
+type LockoutHandler struct {
+	svc    lockoutService
+	logger *logger.Logger
+}
+
+func NewLockoutHandler(svc lockoutService, log *logger.Logger) *LockoutHandler {
+	return &LockoutHandler{svc: svc, logger: log}
+}
+
+func (h *LockoutHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("lockout list attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	lockouts, err := h.svc.List(r.Context(), token)
+	if err != nil {
+		h.logger.Error.Printf("failed to list lockouts: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.LockoutsList(lockouts))
+}
+
+// HandleClear lifts the lockout given as ?login=<login> or ?ip=<address>.
+func (h *LockoutHandler) HandleClear(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("lockout clear attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	kind, value := models.LockoutLogin, r.URL.Query().Get("login")
+	if value == "" {
+		kind, value = models.LockoutIP, r.URL.Query().Get("ip")
+	}
+	if value == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("login or ip required"))
+		return
+	}
+
+	if err := h.svc.Clear(r.Context(), token, kind, value); err != nil {
+		h.logger.Error.Printf("failed to clear lockout of %s %s: %v", kind, value, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("lockout of %s %s cleared", kind, value)
+	utils.WriteJSON(w, http.StatusOK, utils.LockoutClearedResp(kind, value))
+}
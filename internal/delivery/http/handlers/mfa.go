@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	utils "docs_storage/internal/utils"
+	"docs_storage/pkg/logger"
+)
+
+type mfaService interface {
+	Enroll(ctx context.Context, token string) (secret, uri string, err error)
+	Confirm(ctx context.Context, token, code string) ([]string, error)
+	Disable(ctx context.Context, token, code string) error
+	Reset(ctx context.Context, token string, userID int) error
+}
+
+type MFAHandler struct {
+	svc    mfaService
+	logger *logger.Logger
+}
+
+func NewMFAHandler(svc mfaService, log *logger.Logger) *MFAHandler {
+	return &MFAHandler{svc: svc, logger: log}
+}
+
+func (h *MFAHandler) HandleEnroll(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("2fa enroll attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	secret, uri, err := h.svc.Enroll(r.Context(), token)
+	if err != nil {
+		h.logger.Error.Printf("failed to enroll 2fa: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.TOTPEnrollResp(secret, uri))
+}
+
+func (h *MFAHandler) HandleConfirm(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("2fa confirm attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	code, ok := h.decodeCode(w, r)
+	if !ok {
+		return
+	}
+
+	codes, err := h.svc.Confirm(r.Context(), token, code)
+	if err != nil {
+		h.logger.Error.Printf("failed to confirm 2fa: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Print("2fa enabled")
+	utils.WriteJSON(w, http.StatusOK, utils.RecoveryCodesResp(codes))
+}
+
+func (h *MFAHandler) HandleDisable(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("2fa disable attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	code, ok := h.decodeCode(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.svc.Disable(r.Context(), token, code); err != nil {
+		h.logger.Error.Printf("failed to disable 2fa: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Print("2fa disabled")
+	utils.WriteJSON(w, http.StatusOK, utils.TOTPDisabledResp())
+}
+
+func (h *MFAHandler) HandleReset(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("2fa reset attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid user id"))
+		return
+	}
+
+	if err := h.svc.Reset(r.Context(), token, id); err != nil {
+		h.logger.Error.Printf("failed to reset 2fa for user %d: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("2fa of user %d reset", id)
+	utils.WriteJSON(w, http.StatusOK, utils.TOTPDisabledResp())
+}
+
+func (h *MFAHandler) decodeCode(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.logger.Error.Printf("failed to decode 2fa input: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return "", false
+	}
+	if input.Code == "" {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("code required"))
+		return "", false
+	}
+	return input.Code, true
+}
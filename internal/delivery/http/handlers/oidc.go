@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	service "docs_storage/internal/service"
+	utils "docs_storage/internal/utils"
+	"docs_storage/pkg/logger"
+)
+
+const (
+	oidcFlowCookie = "oidc_flow"
+	oidcFlowMaxAge = 10 * time.Minute
+)
+
+type oidcService interface {
+	LoginURL(ctx context.Context) (string, *service.OIDCFlow, error)
+	Callback(ctx context.Context, flow *service.OIDCFlow, state, code string) (*service.AuthTokens, error)
+	Link(ctx context.Context, token string, userID int, subject string) error
+	Unlink(ctx context.Context, token string, userID int) error
+}
+
+type OIDCHandler struct {
+	svc          oidcService
+	logger       *logger.Logger
+	secureCookie bool
+}
+
+func NewOIDCHandler(svc oidcService, log *logger.Logger, secureCookie bool) *OIDCHandler {
+	return &OIDCHandler{svc: svc, logger: log, secureCookie: secureCookie}
+}
+
+func (h *OIDCHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	url, flow, err := h.svc.LoginURL(r.Context())
+	if err != nil {
+		h.logger.Error.Printf("failed to start oidc login: %v", err)
+		utils.WriteJSON(w, http.StatusBadGateway, utils.ErrorResp("identity provider unavailable"))
+		return
+	}
+
+	raw, err := json.Marshal(flow)
+	if err != nil {
+		h.logger.Error.Printf("failed to encode oidc flow: %v", err)
+		utils.WriteJSON(w, http.StatusInternalServerError, utils.ErrorResp("cannot start login"))
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(raw),
+		Path:     "/api/auth/oidc",
+		MaxAge:   int(oidcFlowMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   h.secureCookie,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+func (h *OIDCHandler) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		h.logger.Error.Printf("oidc login rejected by provider: %s: %s", e, q.Get("error_description"))
+		utils.WriteJSON(w, http.StatusUnauthorized, utils.ErrorResp(e))
+		return
+	}
+
+	var flow *service.OIDCFlow
+	if c, err := r.Cookie(oidcFlowCookie); err == nil {
+		if raw, err := base64.RawURLEncoding.DecodeString(c.Value); err == nil {
+			_ = json.Unmarshal(raw, &flow)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcFlowCookie,
+		Path:     "/api/auth/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   h.secureCookie,
+	})
+
+	tokens, err := h.svc.Callback(r.Context(), flow, q.Get("state"), q.Get("code"))
+	if err != nil {
+		h.logger.Error.Printf("oidc login failed: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusUnauthorized), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Print("user authenticated via oidc")
+	utils.WriteJSON(w, http.StatusOK, authResp(tokens))
+}
+
+// HandleLinkIdentity connects an existing account to the identity provider
+// account with the given subject, so that it can log in through SSO.
+func (h *OIDCHandler) HandleLinkIdentity(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("link identity attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid user id"))
+		return
+	}
+
+	var input struct {
+		Subject string `json:"subject"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.logger.Error.Printf("failed to decode link identity input: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+
+	if err := h.svc.Link(r.Context(), token, id, input.Subject); err != nil {
+		h.logger.Error.Printf("failed to link identity to user %d: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("oidc identity linked to user %d by token %s", id, token)
+	utils.WriteJSON(w, http.StatusOK, utils.IdentityLinkResp(id, true))
+}
+
+func (h *OIDCHandler) HandleUnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("unlink identity attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid user id"))
+		return
+	}
+
+	if err := h.svc.Unlink(r.Context(), token, id); err != nil {
+		h.logger.Error.Printf("failed to unlink identity of user %d: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("oidc identity unlinked from user %d by token %s", id, token)
+	utils.WriteJSON(w, http.StatusOK, utils.IdentityLinkResp(id, false))
+}
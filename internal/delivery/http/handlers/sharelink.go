@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	models "docs_storage/internal/models"
+	utils "docs_storage/internal/utils"
+	"docs_storage/pkg/logger"
+	ratelimit "docs_storage/pkg/ratelimit"
+)
+
+type shareLinkService interface {
+	Create(ctx context.Context, token, docID string, ttl time.Duration, password string, maxDownloads int) (*models.ShareLink, error)
+	List(ctx context.Context, token string) ([]models.ShareLink, error)
+	Revoke(ctx context.Context, token, id string) error
+	Open(ctx context.Context, linkToken, password string, count bool) (*models.Document, error)
+}
+
+type ShareLinkHandler struct {
+	svc    shareLinkService
+	files  fileOpener
+	logger *logger.Logger
+
+	// guesses limits requests carrying a link password per link and client
+	// address, so that the password cannot be brute-forced.
+	guesses    *ratelimit.Limiter
+	trustProxy bool
+}
+
+func NewShareLinkHandler(svc shareLinkService, files fileOpener, log *logger.Logger, guesses *ratelimit.Limiter, trustProxy bool) *ShareLinkHandler {
+	return &ShareLinkHandler{svc: svc, files: files, logger: log, guesses: guesses, trustProxy: trustProxy}
+}
+
+func (h *ShareLinkHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("create share link attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	var input struct {
+		// ExpiresIn is in seconds; zero means the server default.
+		ExpiresIn    int    `json:"expires_in"`
+		Password     string `json:"password"`
+		MaxDownloads int    `json:"max_downloads"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.logger.Error.Printf("failed to decode share link input: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+
+	l, err := h.svc.Create(r.Context(), token, id, time.Duration(input.ExpiresIn)*time.Second, input.Password, input.MaxDownloads)
+	if err != nil {
+		h.logger.Error.Printf("failed to create share link for document %s: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("share link %s created for document %s", l.ID, id)
+	utils.WriteJSON(w, http.StatusCreated, utils.ShareLinkDetail(*l))
+}
+
+func (h *ShareLinkHandler) HandleList(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("list share links attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	links, err := h.svc.List(r.Context(), token)
+	if err != nil {
+		h.logger.Error.Printf("failed to list share links: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, utils.ShareLinksList(links))
+}
+
+func (h *ShareLinkHandler) HandleRevoke(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("revoke share link attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	if err := h.svc.Revoke(r.Context(), token, id); err != nil {
+		h.logger.Error.Printf("failed to revoke share link %s: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("share link %s revoked", id)
+	utils.WriteJSON(w, http.StatusOK, utils.DeleteResp(id))
+}
+
+// HandleOpen serves the document behind a share link to anyone holding it.
+// A link password is taken from the X-Link-Password header or, for a POST,
+// from the password field of a form or JSON body. It is never read from the
+// URL, which would leave it in logs and browser history.
+func (h *ShareLinkHandler) HandleOpen(w http.ResponseWriter, r *http.Request) {
+	linkToken := mux.Vars(r)["token"]
+	password := r.Header.Get("X-Link-Password")
+	if password == "" && r.Method == http.MethodPost {
+		var err error
+		if password, err = linkPassword(w, r); err != nil {
+			h.logger.Error.Printf("failed to read share link password: %v", err)
+			utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("cannot read password"))
+			return
+		}
+	}
+
+	if password != "" {
+		ip := utils.ClientIP(r, h.trustProxy)
+		if ok, wait := h.guesses.Allow(linkToken + "|" + ip); !ok {
+			h.logger.Error.Printf("share link password rate limit exceeded by %s", ip)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			utils.WriteJSON(w, http.StatusTooManyRequests, utils.ErrorResp("rate limit exceeded"))
+			return
+		}
+	}
+
+	count := r.Method != http.MethodHead
+	if count && continuesDownload(r.Header.Get("Range")) {
+		// The range must then be served as asked: a failed If-Range would
+		// send the whole file without counting it.
+		r.Header.Del("If-Range")
+		count = false
+	}
+
+	doc, err := h.svc.Open(r.Context(), linkToken, password, count)
+	if err != nil {
+		h.logger.Error.Printf("failed to open share link: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("document %s retrieved through share link", doc.ID)
+	if doc.File {
+		serveFile(w, r, h.files, h.logger, doc.FilePath, doc.Name, doc.Mime, doc.CreatedAt)
+		return
+	}
+	if r.Method != http.MethodHead && !count {
+		utils.WriteJSON(w, http.StatusRequestedRangeNotSatisfiable, utils.ErrorResp("document has no file to take a range of"))
+		return
+	}
+
+	// Who else the document is shared with is none of the link holder's
+	// business.
+	doc.ACL = nil
+	utils.WriteJSON(w, http.StatusOK, utils.DocDetail(*doc))
+}
+
+// maxLinkPasswordBody bounds the body of a POST carrying a link password.
+const maxLinkPasswordBody = 4 << 10
+
+func linkPassword(w http.ResponseWriter, r *http.Request) (string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxLinkPasswordBody)
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		if err := r.ParseForm(); err != nil {
+			return "", err
+		}
+		return r.PostForm.Get("password"), nil
+	}
+
+	var input struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		return "", err
+	}
+	return input.Password, nil
+}
+
+// continuesDownload reports whether a Range header asks for a single range
+// that starts after the first byte, as when a download is resumed or split.
+// Such requests are not counted against the link's limit, since the first
+// byte of the file always takes a counted one. Several ranges, suffix ranges
+// and anything http.ServeContent would not honour as given may well cover
+// the whole file and are counted.
+func continuesDownload(header string) bool {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return false
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return false
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(first), 10, 64)
+	if err != nil || start <= 0 {
+		return false
+	}
+	if last = strings.TrimSpace(last); last != "" {
+		end, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	models "docs_storage/internal/models"
+	utils "docs_storage/internal/utils"
+	"docs_storage/pkg/logger"
+)
+
+const tusVersion = "1.0.0"
+
+type uploadService interface {
+	Create(ctx context.Context, token string, length int64, metadata map[string]string) (*models.Upload, error)
+	Get(ctx context.Context, token, id string) (*models.Upload, error)
+	Append(ctx context.Context, token, id string, offset int64, r io.Reader) (*models.Upload, *models.Document, error)
+	Terminate(ctx context.Context, token, id string) error
+	MaxSize() int64
+	ExpiresAt(u *models.Upload) time.Time
+}
+
+// UploadHandler implements the core tus 1.0.0 protocol together with the
+// creation, termination and expiration extensions.
+type UploadHandler struct {
+	svc    uploadService
+	logger *logger.Logger
+}
+
+func NewUploadHandler(svc uploadService, log *logger.Logger) *UploadHandler {
+	return &UploadHandler{svc: svc, logger: log}
+}
+
+func (h *UploadHandler) HandleOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", "creation,termination,expiration")
+	if max := h.svc.MaxSize(); max > 0 {
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(max, 10))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UploadHandler) HandleCreate(w http.ResponseWriter, r *http.Request) {
+	if !h.checkVersion(w, r) {
+		return
+	}
+
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("upload creation attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid Upload-Length"))
+		return
+	}
+
+	metadata, err := parseUploadMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid Upload-Metadata"))
+		return
+	}
+
+	u, err := h.svc.Create(r.Context(), token, length, metadata)
+	if err != nil {
+		h.logger.Error.Printf("failed to create upload: %v", err)
+		h.writeError(w, err)
+		return
+	}
+
+	h.logger.Info.Printf("upload created: %s, length %d by token %s", u.ID, u.Length, token)
+	w.Header().Set("Location", "/api/uploads/"+u.ID)
+	h.setExpires(w, u)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (h *UploadHandler) HandleHead(w http.ResponseWriter, r *http.Request) {
+	if !h.checkVersion(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	u, err := h.svc.Get(r.Context(), token, id)
+	if err != nil {
+		h.logger.Error.Printf("failed to get upload %s: %v", id, err)
+		w.WriteHeader(errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	h.setExpires(w, u)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *UploadHandler) HandlePatch(w http.ResponseWriter, r *http.Request) {
+	if !h.checkVersion(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("upload patch attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		utils.WriteJSON(w, http.StatusUnsupportedMediaType, utils.ErrorResp("Content-Type must be application/offset+octet-stream"))
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid Upload-Offset"))
+		return
+	}
+
+	u, doc, err := h.svc.Append(r.Context(), token, id, offset, r.Body)
+	if u != nil {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	}
+	if err != nil {
+		h.logger.Error.Printf("failed to append to upload %s: %v", id, err)
+		h.writeError(w, err)
+		return
+	}
+
+	if doc != nil {
+		h.logger.Info.Printf("upload %s finished as document %s by token %s", id, doc.ID, token)
+		w.Header().Set("X-Document-Id", doc.ID)
+	} else {
+		h.setExpires(w, u)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UploadHandler) HandleTerminate(w http.ResponseWriter, r *http.Request) {
+	if !h.checkVersion(w, r) {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("upload termination attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	if err := h.svc.Terminate(r.Context(), token, id); err != nil {
+		h.logger.Error.Printf("failed to terminate upload %s: %v", id, err)
+		h.writeError(w, err)
+		return
+	}
+
+	h.logger.Info.Printf("upload terminated: %s by token %s", id, token)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *UploadHandler) checkVersion(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func (h *UploadHandler) setExpires(w http.ResponseWriter, u *models.Upload) {
+	if expires := h.svc.ExpiresAt(u); !expires.IsZero() {
+		w.Header().Set("Upload-Expires", expires.UTC().Format(http.TimeFormat))
+	}
+}
+
+func (h *UploadHandler) writeError(w http.ResponseWriter, err error) {
+	utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+}
+
+// parseUploadMetadata decodes the tus Upload-Metadata header: comma
+// separated pairs of a key and an optional base64 encoded value.
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	models "docs_storage/internal/models"
+	utils "docs_storage/internal/utils"
+	"docs_storage/pkg/logger"
+)
+
+type userService interface {
+	List(ctx context.Context, token string, limit, offset int) ([]models.User, int, error)
+	SetDisabled(ctx context.Context, token string, id int, disabled bool) error
+	Delete(ctx context.Context, token string, id int, reassignTo string) error
+	ResetPassword(ctx context.Context, token string, id int, pswd string) error
+	ChangePassword(ctx context.Context, token, oldPswd, newPswd string) error
+}
+
+type UserHandler struct {
+	svc    userService
+	logger *logger.Logger
+}
+
+func NewUserHandler(svc userService, log *logger.Logger) *UserHandler {
+	return &UserHandler{svc: svc, logger: log}
+}
+
+func (h *UserHandler) HandleListUsers(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("list users attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	limit, offset := 0, 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if n, err := strconv.Atoi(l); err == nil {
+			limit = n
+		}
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if n, err := strconv.Atoi(o); err == nil {
+			offset = n
+		}
+	}
+
+	users, total, err := h.svc.List(r.Context(), token, limit, offset)
+	if err != nil {
+		h.logger.Error.Printf("failed to list users: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("users listed by token %s, count: %d", token, len(users))
+	utils.WriteJSON(w, http.StatusOK, utils.UsersList(users, total))
+}
+
+func (h *UserHandler) HandleDisableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, true)
+}
+
+func (h *UserHandler) HandleEnableUser(w http.ResponseWriter, r *http.Request) {
+	h.setDisabled(w, r, false)
+}
+
+func (h *UserHandler) setDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("disable user attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid user id"))
+		return
+	}
+
+	if err := h.svc.SetDisabled(r.Context(), token, id, disabled); err != nil {
+		h.logger.Error.Printf("failed to set disabled=%t for user %d: %v", disabled, id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("user %d disabled=%t by token %s", id, disabled, token)
+	utils.WriteJSON(w, http.StatusOK, utils.UserStatusResp(id, disabled))
+}
+
+func (h *UserHandler) HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("delete user attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid user id"))
+		return
+	}
+
+	reassignTo := r.URL.Query().Get("reassign_to")
+	if err := h.svc.Delete(r.Context(), token, id, reassignTo); err != nil {
+		h.logger.Error.Printf("failed to delete user %d: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("user %d deleted by token %s, documents reassigned to %q", id, token, reassignTo)
+	utils.WriteJSON(w, http.StatusOK, utils.DeleteResp(strconv.Itoa(id)))
+}
+
+func (h *UserHandler) HandleResetPassword(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("reset password attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("invalid user id"))
+		return
+	}
+
+	var input struct {
+		Pswd string `json:"pswd"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.logger.Error.Printf("failed to decode reset password input: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+
+	if err := h.svc.ResetPassword(r.Context(), token, id, input.Pswd); err != nil {
+		h.logger.Error.Printf("failed to reset password for user %d: %v", id, err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("password of user %d reset by token %s", id, token)
+	utils.WriteJSON(w, http.StatusOK, utils.PasswordChangedResp())
+}
+
+func (h *UserHandler) HandleChangePassword(w http.ResponseWriter, r *http.Request) {
+	token := utils.ExtractToken(r)
+	if token == "" {
+		h.logger.Error.Print("change password attempt without token")
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp("token required"))
+		return
+	}
+
+	var input struct {
+		OldPswd string `json:"old_pswd"`
+		NewPswd string `json:"new_pswd"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.logger.Error.Printf("failed to decode change password input: %v", err)
+		utils.WriteJSON(w, http.StatusBadRequest, utils.ErrorResp(err.Error()))
+		return
+	}
+
+	if err := h.svc.ChangePassword(r.Context(), token, input.OldPswd, input.NewPswd); err != nil {
+		h.logger.Error.Printf("failed to change password: %v", err)
+		utils.WriteJSON(w, errorStatus(err, http.StatusInternalServerError), utils.ErrorResp(err.Error()))
+		return
+	}
+
+	h.logger.Info.Printf("password changed by token %s", token)
+	utils.WriteJSON(w, http.StatusOK, utils.PasswordChangedResp())
+}
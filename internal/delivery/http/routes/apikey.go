@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+
+	handlers "docs_storage/internal/delivery/http/handlers"
+)
+
+func SetupAPIKeyRoutes(r *mux.Router, keyHandler *handlers.APIKeyHandler) {
+	r.HandleFunc("/api/me/keys", keyHandler.HandleListKeys).Methods("GET")
+	r.HandleFunc("/api/me/keys", keyHandler.HandleCreateKey).Methods("POST")
+	r.HandleFunc("/api/me/keys/{id:[0-9]+}", keyHandler.HandleRevokeKey).Methods("DELETE")
+}
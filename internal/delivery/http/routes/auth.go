@@ -9,5 +9,7 @@ import (
 func SetupAuthRoutes(r *mux.Router, docsHandler *handlers.AuthHandler) {
 	r.HandleFunc("/api/register", docsHandler.Register).Methods("POST")
     r.HandleFunc("/api/auth", docsHandler.Auth).Methods("POST")
+    r.HandleFunc("/api/auth/refresh", docsHandler.Refresh).Methods("POST")
+    r.HandleFunc("/api/auth/mfa", docsHandler.MFA).Methods("POST")
     r.HandleFunc("/api/auth/{token}", docsHandler.Logout).Methods("DELETE")
 }
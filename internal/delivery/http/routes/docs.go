@@ -9,6 +9,18 @@ import (
 func SetupDocsRoutes(r *mux.Router, docsHandler *handlers.DocsHandler) {
 	r.HandleFunc("/api/docs", docsHandler.HandleUploadDoc).Methods("POST")
     r.HandleFunc("/api/docs", docsHandler.HandleListDocs).Methods("GET", "HEAD")
+    // Registered before /api/docs/{id}, which would otherwise take "search"
+    // for an id.
+    r.HandleFunc("/api/docs/search", docsHandler.HandleSearchDocs).Methods("GET")
     r.HandleFunc("/api/docs/{id}", docsHandler.HandleGetDoc).Methods("GET", "HEAD")
+    r.HandleFunc("/api/docs/{id}", docsHandler.HandleUpdateDoc).Methods("PUT")
     r.HandleFunc("/api/docs/{id}", docsHandler.HandleDeleteDoc).Methods("DELETE")
+    r.HandleFunc("/api/docs/{id}", docsHandler.HandlePatchDoc).Methods("PATCH")
+    r.HandleFunc("/api/docs/{id}/share", docsHandler.HandleShareDoc).Methods("POST")
+    r.HandleFunc("/api/docs/{id}/grants", docsHandler.HandleListGrants).Methods("GET")
+    r.HandleFunc("/api/docs/{id}/grants", docsHandler.HandleSetGrant).Methods("POST")
+    r.HandleFunc("/api/docs/{id}/grants", docsHandler.HandleRevokeGrant).Methods("DELETE")
+    r.HandleFunc("/api/docs/{id}/versions", docsHandler.HandleListVersions).Methods("GET", "HEAD")
+    r.HandleFunc("/api/docs/{id}/versions/{version:[0-9]+}", docsHandler.HandleGetVersion).Methods("GET", "HEAD")
+    r.HandleFunc("/api/docs/{id}/versions/{version:[0-9]+}/restore", docsHandler.HandleRestoreVersion).Methods("POST")
 }
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+
+	handlers "docs_storage/internal/delivery/http/handlers"
+)
+
+func SetupGroupRoutes(r *mux.Router, groupHandler *handlers.GroupHandler) {
+	r.HandleFunc("/api/groups", groupHandler.HandleList).Methods("GET")
+	r.HandleFunc("/api/admin/groups", groupHandler.HandleCreate).Methods("POST")
+	r.HandleFunc("/api/admin/groups/{id:[0-9]+}", groupHandler.HandleGet).Methods("GET")
+	r.HandleFunc("/api/admin/groups/{id:[0-9]+}", groupHandler.HandleUpdate).Methods("PATCH")
+	r.HandleFunc("/api/admin/groups/{id:[0-9]+}", groupHandler.HandleDelete).Methods("DELETE")
+	r.HandleFunc("/api/admin/groups/{id:[0-9]+}/members/{user_id:[0-9]+}", groupHandler.HandleAddMember).Methods("PUT")
+	r.HandleFunc("/api/admin/groups/{id:[0-9]+}/members/{user_id:[0-9]+}", groupHandler.HandleRemoveMember).Methods("DELETE")
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+
+	handlers "docs_storage/internal/delivery/http/handlers"
+)
+
+func SetupLockoutRoutes(r *mux.Router, lockoutHandler *handlers.LockoutHandler) {
+	r.HandleFunc("/api/admin/lockouts", lockoutHandler.HandleList).Methods("GET")
+	r.HandleFunc("/api/admin/lockouts", lockoutHandler.HandleClear).Methods("DELETE")
+}
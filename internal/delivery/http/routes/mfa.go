@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+
+	handlers "docs_storage/internal/delivery/http/handlers"
+)
+
+func SetupMFARoutes(r *mux.Router, mfaHandler *handlers.MFAHandler) {
+	r.HandleFunc("/api/me/2fa", mfaHandler.HandleEnroll).Methods("POST")
+	r.HandleFunc("/api/me/2fa/confirm", mfaHandler.HandleConfirm).Methods("POST")
+	r.HandleFunc("/api/me/2fa", mfaHandler.HandleDisable).Methods("DELETE")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/2fa", mfaHandler.HandleReset).Methods("DELETE")
+}
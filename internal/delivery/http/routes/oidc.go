@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+
+	handlers "docs_storage/internal/delivery/http/handlers"
+)
+
+func SetupOIDCRoutes(r *mux.Router, oidcHandler *handlers.OIDCHandler) {
+	r.HandleFunc("/api/auth/oidc/login", oidcHandler.HandleLogin).Methods("GET")
+	r.HandleFunc("/api/auth/oidc/callback", oidcHandler.HandleCallback).Methods("GET")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/oidc", oidcHandler.HandleLinkIdentity).Methods("PUT")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/oidc", oidcHandler.HandleUnlinkIdentity).Methods("DELETE")
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+
+	handlers "docs_storage/internal/delivery/http/handlers"
+)
+
+func SetupShareLinkRoutes(r *mux.Router, shareLinkHandler *handlers.ShareLinkHandler) {
+	r.HandleFunc("/api/docs/{id}/links", shareLinkHandler.HandleCreate).Methods("POST")
+	r.HandleFunc("/api/links", shareLinkHandler.HandleList).Methods("GET")
+	r.HandleFunc("/api/links/{id}", shareLinkHandler.HandleRevoke).Methods("DELETE")
+	r.HandleFunc("/s/{token}", shareLinkHandler.HandleOpen).Methods("GET", "HEAD", "POST")
+}
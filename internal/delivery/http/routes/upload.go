@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+
+	handlers "docs_storage/internal/delivery/http/handlers"
+)
+
+func SetupUploadRoutes(r *mux.Router, uploadHandler *handlers.UploadHandler) {
+	r.HandleFunc("/api/uploads", uploadHandler.HandleOptions).Methods("OPTIONS")
+	r.HandleFunc("/api/uploads", uploadHandler.HandleCreate).Methods("POST")
+	r.HandleFunc("/api/uploads/{id}", uploadHandler.HandleOptions).Methods("OPTIONS")
+	r.HandleFunc("/api/uploads/{id}", uploadHandler.HandleHead).Methods("HEAD")
+	r.HandleFunc("/api/uploads/{id}", uploadHandler.HandlePatch).Methods("PATCH")
+	r.HandleFunc("/api/uploads/{id}", uploadHandler.HandleTerminate).Methods("DELETE")
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"github.com/gorilla/mux"
+
+	handlers "docs_storage/internal/delivery/http/handlers"
+)
+
+func SetupUserRoutes(r *mux.Router, userHandler *handlers.UserHandler) {
+	r.HandleFunc("/api/admin/users", userHandler.HandleListUsers).Methods("GET")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}", userHandler.HandleDeleteUser).Methods("DELETE")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/disable", userHandler.HandleDisableUser).Methods("POST")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/enable", userHandler.HandleEnableUser).Methods("POST")
+	r.HandleFunc("/api/admin/users/{id:[0-9]+}/password", userHandler.HandleResetPassword).Methods("POST")
+	r.HandleFunc("/api/me/password", userHandler.HandleChangePassword).Methods("POST")
+}
@@ -0,0 +1,27 @@
+package models
+
+import "slices"
+
+// Permissions a document's owner can hand out. Every grantee may read; the
+// others are granted on top.
+const (
+	PermRead   = "read"
+	PermWrite  = "write"
+	PermShare  = "share"
+	PermDelete = "delete"
+)
+
+// ACLEntry grants Permissions on a document to Grantee, a login, a
+// GroupPrincipal or a DirectoryPrincipal.
+type ACLEntry struct {
+	Grantee     string   `json:"grantee" db:"grantee"`
+	Permissions []string `json:"permissions" db:"permissions"`
+}
+
+func ValidPermission(perm string) bool {
+	return slices.Contains([]string{PermRead, PermWrite, PermShare, PermDelete}, perm)
+}
+
+func (e ACLEntry) Allows(perm string) bool {
+	return perm == PermRead || slices.Contains(e.Permissions, perm)
+}
\ No newline at end of file
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// APIKeyPrefix marks API keys so they can be told apart from session tokens.
+const APIKeyPrefix = "dsk_"
+
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeDelete = "delete"
+	ScopeAdmin  = "admin"
+)
+
+type APIKey struct {
+	ID         int        `db:"id"`
+	UserID     int        `db:"user_id"`
+	Name       string     `db:"name"`
+	Prefix     string     `db:"prefix"`
+	Scopes     []string   `db:"scopes"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	CreatedAt  time.Time  `db:"created_at"`
+}
+
+func ValidScope(scope string) bool {
+	return slices.Contains([]string{ScopeRead, ScopeWrite, ScopeDelete, ScopeAdmin}, scope)
+}
\ No newline at end of file
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type Blob struct {
+	Checksum  string    `json:"checksum" db:"checksum"`
+	Key       string    `json:"key" db:"file_path"`
+	Size      int64     `json:"size" db:"size"`
+	RefCount  int       `json:"ref_count" db:"ref_count"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
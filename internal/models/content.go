@@ -0,0 +1,14 @@
+package models
+
+const (
+	ExtractionPending     = "pending"
+	ExtractionDone        = "done"
+	ExtractionFailed      = "failed"
+	ExtractionUnsupported = "unsupported"
+)
+
+// ExtractionJob asks for the text of one version of a document's file.
+type ExtractionJob struct {
+	DocumentID string
+	Version    int
+}
\ No newline at end of file
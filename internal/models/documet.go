@@ -9,8 +9,82 @@ type Document struct {
 	File       bool      `json:"file" db:"file"`
 	Public     bool      `json:"public" db:"public"`
 	OwnerLogin string    `json:"owner_login" db:"owner_login"`
-	Grant      []string  `json:"grant" db:"grant_list"`
 	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 	JSONData   []byte    `json:"json_data" db:"json_data"`
 	FilePath   string    `json:"file_path" db:"file_path"`
+	Version    int       `json:"version" db:"version"`
+	Checksum   string    `json:"checksum" db:"checksum"`
+	// ACL lists who besides the owner may access the document.
+	ACL []ACLEntry `json:"acl" db:"-"`
+	// Grant is accepted on upload as shorthand for read-only ACL entries.
+	Grant []string `json:"grant" db:"-"`
+	// ExtractionStatus tells how far getting the text out of the file has
+	// come. It is empty for documents without a file.
+	ExtractionStatus string `json:"extraction_status" db:"-"`
 }
+
+// DocumentQuery is what a client asks of a listing.
+type DocumentQuery struct {
+	Login string
+	Key   string
+	Value string
+	Limit int
+	// Sort names the field to order by, prefixed with "-" for descending
+	// order. Empty means by name.
+	Sort string
+	// Cursor continues a listing where the page it came with ended.
+	Cursor    string
+	WithTotal bool
+	// Filters must all hold for a document to be listed.
+	Filters []DocumentCondition
+}
+
+// JSONFieldPrefix marks condition fields that address a path inside
+// json_data rather than a column, e.g. "json.invoice.amount".
+const JSONFieldPrefix = "json."
+
+// DocumentCondition compares a document field with a value, e.g.
+// {"mime", "eq", "application/pdf"}. For the "in" operator Value is a comma
+// separated list.
+type DocumentCondition struct {
+	Field string
+	Op    string
+	Value string
+}
+
+type DocumentFilter struct {
+	RequesterLogin string
+	// RequesterPrincipals are the names the requester may appear under in a
+	// document's ACL, see Session.Principals.
+	RequesterPrincipals []string
+	All                 bool
+	// PublicOnly restricts the listing to public documents, for requests
+	// without a session.
+	PublicOnly bool
+	DocumentQuery
+}
+
+// DocumentPage is one page of a listing. NextCursor is empty on the last
+// page; Total is only counted when asked for.
+type DocumentPage struct {
+	Docs       []Document
+	NextCursor string
+	Total      *int
+}
+
+// Grantees lists the logins and groups that have any access through the ACL.
+func (d *Document) Grantees() []string {
+	grantees := make([]string, 0, len(d.ACL))
+	for _, e := range d.ACL {
+		grantees = append(grantees, e.Grantee)
+	}
+	return grantees
+}
+
+// SearchResult is a document found by full-text search. Snippet is HTML
+// with the matched words in <mark> tags.
+type SearchResult struct {
+	Document
+	Rank    float32
+	Snippet string
+}
\ No newline at end of file
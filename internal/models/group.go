@@ -0,0 +1,26 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Group is a locally managed set of users that documents can be granted to
+// as GroupPrincipal(Name).
+type Group struct {
+	ID          int       `db:"id"`
+	Name        string    `db:"name"`
+	Description string    `db:"description"`
+	CreatedAt   time.Time `db:"created_at"`
+	MemberCount int       `db:"member_count"`
+	// Members holds member logins. It is only filled when a single group is
+	// fetched.
+	Members []string `db:"-"`
+}
+
+// ValidGroupName rejects names that would be ambiguous in ACLs and
+// cache keys.
+func ValidGroupName(name string) bool {
+	return name != "" && len(name) <= 64 && name == strings.TrimSpace(name) &&
+		!strings.ContainsAny(name, ":,")
+}
\ No newline at end of file
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+const (
+	LockoutLogin = "login"
+	LockoutIP    = "ip"
+)
+
+// Lockout is a login name or client address that may not try to log in
+// again before BlockedUntil.
+type Lockout struct {
+	Kind         string
+	Value        string
+	Failures     int
+	BlockedUntil time.Time
+}
\ No newline at end of file
@@ -1,10 +1,66 @@
 package models
 
-import "time"
+import (
+	"slices"
+	"time"
+)
 
 type Session struct {
-	Token     string    `db:"token"`
-	UserID    int       `db:"user_id"`
-	Login     string    `db:"login"`
-	CreatedAt time.Time `db:"created_at"`
+	Token      string    `db:"token"`
+	UserID     int       `db:"user_id"`
+	Login      string    `db:"login"`
+	Role       string    `db:"role"`
+	CreatedAt  time.Time `db:"created_at"`
+	LastSeenAt time.Time `db:"last_seen_at"`
+	// Groups are the user's local groups, granted to as GroupPrincipal(name).
+	Groups []string `db:"groups"`
+	// DirectoryGroups are those reported by the directory at the last login,
+	// granted to as DirectoryPrincipal(name). They are kept apart from the
+	// local ones, so that a same-named directory group does not get in.
+	DirectoryGroups []string `db:"directory_groups"`
+	// Scopes limits what an API key may do. Interactive sessions leave it
+	// nil and are allowed everything their role permits.
+	Scopes []string `db:"scopes"`
 }
+
+const (
+	groupPrefix     = "group:"
+	directoryPrefix = "ldap:"
+)
+
+// GroupPrincipal is how a local group appears in a document's grant list.
+func GroupPrincipal(group string) string {
+	return groupPrefix + group
+}
+
+// DirectoryPrincipal is how a directory group appears in a document's grant
+// list.
+func DirectoryPrincipal(group string) string {
+	return directoryPrefix + group
+}
+
+// Principals lists every name the session can be granted access under: the
+// login itself and each of its groups.
+func (s *Session) Principals() []string {
+	p := make([]string, 0, len(s.Groups)+len(s.DirectoryGroups)+1)
+	p = append(p, s.Login)
+	for _, g := range s.Groups {
+		p = append(p, GroupPrincipal(g))
+	}
+	for _, g := range s.DirectoryGroups {
+		p = append(p, DirectoryPrincipal(g))
+	}
+	return p
+}
+
+func (s *Session) IsAdmin() bool {
+	return s.Role == RoleAdmin && s.HasScope(ScopeAdmin)
+}
+
+func (s *Session) IsAPIKey() bool {
+	return s.Scopes != nil
+}
+
+func (s *Session) HasScope(scope string) bool {
+	return s.Scopes == nil || slices.Contains(s.Scopes, scope)
+}
\ No newline at end of file
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ShareLink lets anyone with its token download a document until it expires
+// or runs out of downloads.
+type ShareLink struct {
+	ID           string    `db:"id"`
+	DocumentID   string    `db:"document_id"`
+	DocumentName string    `db:"-"`
+	CreatedBy    string    `db:"created_by"`
+	ExpiresAt    time.Time `db:"expires_at"`
+	PasswordHash string    `db:"password_hash"`
+	// MaxDownloads is nil for links that can be used any number of times.
+	MaxDownloads *int      `db:"max_downloads"`
+	Downloads    int       `db:"downloads"`
+	CreatedAt    time.Time `db:"created_at"`
+	// Token is the signed form of the link that is handed out. It is not
+	// stored.
+	Token string `db:"-"`
+}
+
+func (l *ShareLink) HasPassword() bool {
+	return l.PasswordHash != ""
+}
\ No newline at end of file
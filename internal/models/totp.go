@@ -0,0 +1,10 @@
+package models
+
+// TOTP is a user's second factor. It is enrolled with Enabled unset and only
+// enforced at login once the user has confirmed a code.
+type TOTP struct {
+	UserID   int    `db:"id"`
+	Secret   string `db:"totp_secret"`
+	Enabled  bool   `db:"totp_enabled"`
+	LastStep int64  `db:"totp_last_step"`
+}
\ No newline at end of file
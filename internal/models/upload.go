@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type Upload struct {
+	ID         string            `json:"id"`
+	OwnerLogin string            `json:"owner_login"`
+	Length     int64             `json:"length"`
+	Offset     int64             `json:"-"`
+	Metadata   map[string]string `json:"metadata"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"-"`
+}
@@ -2,10 +2,35 @@ package models
 
 import "time"
 
+const (
+    RoleAdmin  = "admin"
+    RoleEditor = "editor"
+    RoleViewer = "viewer"
+)
+
+// Where an account comes from. Accounts made by an outside source have no
+// password and are only logged into through that source.
+const (
+    AuthSourceLocal = "local"
+    AuthSourceLDAP  = "ldap"
+    AuthSourceOIDC  = "oidc"
+)
+
 type User struct {
     ID        int       `json:"id"`
     Login     string    `json:"login"`
     Password  string    `json:"-"`
+    Role      string    `json:"role"`
+    Disabled  bool      `json:"disabled"`
     CreatedAt time.Time `json:"created_at"`
+    // ExternalGroups are the directory groups seen at the last login.
+    ExternalGroups []string `json:"external_groups"`
+    // Groups are the local groups the user is a member of.
+    Groups []string `json:"groups"`
+    // AuthSource is one of the AuthSource constants.
+    AuthSource string `json:"auth_source"`
 }
 
+func ValidRole(role string) bool {
+    return role == RoleAdmin || role == RoleEditor || role == RoleViewer
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type DocumentVersion struct {
+	DocumentID string    `json:"document_id" db:"document_id"`
+	Version    int       `json:"version" db:"version"`
+	Mime       string    `json:"mime" db:"mime"`
+	File       bool      `json:"file" db:"file"`
+	JSONData   []byte    `json:"json_data" db:"json_data"`
+	FilePath   string    `json:"file_path" db:"file_path"`
+	Checksum   string    `json:"checksum" db:"checksum"`
+	CreatedBy  string    `json:"created_by" db:"created_by"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
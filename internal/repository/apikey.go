@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"docs_storage/internal/models"
+)
+
+var ErrAPIKeyExpired = errors.New("api key expired")
+
+var apiKeyColumns = []string{"id", "user_id", "name", "prefix", "scopes", "expires_at", "last_used_at", "created_at"}
+
+type APIKeyRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewAPIKeyRepo(db *pgxpool.Pool) *APIKeyRepo {
+	return &APIKeyRepo{db: db}
+}
+
+// Create stores k under the hash of key; the key itself is never persisted.
+func (r *APIKeyRepo) Create(ctx context.Context, k *models.APIKey, key string) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Insert("api_keys").
+		Columns("user_id", "name", "prefix", "key_hash", "scopes", "expires_at").
+		Values(k.UserID, k.Name, k.Prefix, hashToken(key), k.Scopes, k.ExpiresAt).
+		Suffix("RETURNING id, created_at").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(ctx, sqlStr, args...).Scan(&k.ID, &k.CreatedAt)
+}
+
+func (r *APIKeyRepo) ListByUser(ctx context.Context, userID int) ([]models.APIKey, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Select(apiKeyColumns...).
+		From("api_keys").
+		Where(sq.Eq{"user_id": userID}).
+		OrderBy("id").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+// GetByKey resolves an API key to a session of its owner restricted to the
+// key's scopes. Like SessionRepo.GetByToken it returns nil for unknown keys
+// and keys of disabled users, and records the time of use.
+func (r *APIKeyRepo) GetByKey(ctx context.Context, key string) (*models.Session, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Select("k.id", "k.user_id", "u.login", "u.role", localGroupsColumn("u"), "u.external_groups", "k.scopes", "k.created_at").
+		Column("COALESCE(k.expires_at <= NOW(), false)").
+		From("api_keys k").
+		Join("users u ON u.id = k.user_id").
+		Where(sq.Eq{"k.key_hash": hashToken(key), "u.disabled": false}).
+		Limit(1).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	s := models.Session{Token: key}
+	var id int
+	var expired bool
+	err = r.db.QueryRow(ctx, sqlStr, args...).
+		Scan(&id, &s.UserID, &s.Login, &s.Role, &s.Groups, &s.DirectoryGroups, &s.Scopes, &s.CreatedAt, &expired)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	// Compared on the database clock, like session expiry.
+	if expired {
+		return nil, ErrAPIKeyExpired
+	}
+	if s.Scopes == nil {
+		s.Scopes = []string{}
+	}
+
+	sqlStr, args, err = builder.
+		Update("api_keys").
+		Set("last_used_at", sq.Expr("NOW()")).
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.db.Exec(ctx, sqlStr, args...); err != nil {
+		return nil, err
+	}
+	s.LastSeenAt = time.Now()
+
+	return &s, nil
+}
+
+// Delete revokes a key. Only the owner's keys are matched, so that a user
+// cannot revoke someone else's key by guessing its id.
+func (r *APIKeyRepo) Delete(ctx context.Context, userID, id int) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Delete("api_keys").
+		Where(sq.Eq{"id": id, "user_id": userID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanAPIKey(row pgx.Row) (*models.APIKey, error) {
+	var k models.APIKey
+	if err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.Scopes, &k.ExpiresAt, &k.LastUsedAt, &k.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
\ No newline at end of file
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	models "docs_storage/internal/models"
+)
+
+type BlobRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewBlobRepo(db *pgxpool.Pool) *BlobRepo {
+	return &BlobRepo{db: db}
+}
+
+// Acquire registers one more reference to the blob, creating its row on the
+// first reference.
+func (r *BlobRepo) Acquire(ctx context.Context, b *models.Blob) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q := builder.
+		Insert("blobs").
+		Columns("checksum", "file_path", "size", "ref_count").
+		Values(b.Checksum, b.Key, b.Size, 1).
+		Suffix("ON CONFLICT (checksum) DO UPDATE SET ref_count = blobs.ref_count + 1")
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, sqlStr, args...)
+	return err
+}
+
+// AddRef registers one more reference to a blob that is already stored.
+func (r *BlobRepo) AddRef(ctx context.Context, checksum string) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q := builder.
+		Update("blobs").
+		Set("ref_count", sq.Expr("ref_count + 1")).
+		Where(sq.Eq{"checksum": checksum})
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Release drops one reference to the blob. When it was the last one, onLast
+// is called with the blob key while the row is still locked, so a concurrent
+// Acquire of the same checksum waits until the stored object is gone and
+// then stores it again instead of pointing at a deleted object.
+func (r *BlobRepo) Release(ctx context.Context, checksum string, onLast func(key string) error) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q := builder.
+		Update("blobs").
+		Set("ref_count", sq.Expr("ref_count - 1")).
+		Where(sq.Eq{"checksum": checksum}).
+		Suffix("RETURNING ref_count, file_path")
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var refCount int
+	var key string
+	if err := tx.QueryRow(ctx, sqlStr, args...).Scan(&refCount, &key); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	if refCount <= 0 {
+		if err := onLast(key); err != nil {
+			return err
+		}
+
+		sqlStr, args, err = builder.Delete("blobs").Where(sq.Eq{"checksum": checksum}).ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, sqlStr, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
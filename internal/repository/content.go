@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"docs_storage/internal/models"
+)
+
+// MarkExtractionPending records that the text of the given version is yet to
+// be extracted. Text from an earlier version stays searchable meanwhile.
+func (r *DocumentRepo) MarkExtractionPending(ctx context.Context, id string, version int) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Insert("document_content").
+		Columns("document_id", "version", "status").
+		Values(id, version, models.ExtractionPending).
+		Suffix(`ON CONFLICT (document_id) DO UPDATE
+			SET version = EXCLUDED.version, status = EXCLUDED.status, error = NULL, updated_at = NOW()
+			WHERE document_content.version <= EXCLUDED.version`).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, sqlStr, args...)
+	return err
+}
+
+// ListPendingExtractions returns up to limit jobs that have been pending for
+// longer than age, oldest first.
+func (r *DocumentRepo) ListPendingExtractions(ctx context.Context, age time.Duration, limit int) ([]models.ExtractionJob, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Select("document_id", "version").
+		From("document_content").
+		Where(sq.Eq{"status": models.ExtractionPending}).
+		Where(sq.Expr("updated_at < NOW() - make_interval(secs => ?)", age.Seconds())).
+		OrderBy("updated_at").
+		Limit(uint64(limit)).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []models.ExtractionJob
+	for rows.Next() {
+		var j models.ExtractionJob
+		if err := rows.Scan(&j.DocumentID, &j.Version); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// SaveExtraction stores the outcome of extracting the given version and
+// reindexes the document for search. It reports false, storing nothing, if
+// a newer version has been uploaded in the meantime.
+func (r *DocumentRepo) SaveExtraction(ctx context.Context, id string, version int, status, text, errMsg string) (bool, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Insert("document_content").
+		Columns("document_id", "version", "status", "text", "error").
+		Values(id, version, status, nullable(text), nullable(errMsg)).
+		Suffix(`ON CONFLICT (document_id) DO UPDATE
+			SET status = EXCLUDED.status, text = EXCLUDED.text, error = EXCLUDED.error, updated_at = NOW()
+			WHERE document_content.version = EXCLUDED.version`).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	cmd, err := tx.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return false, err
+	}
+	if cmd.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	if err := r.refreshSearch(ctx, tx, id); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// DeleteExtraction drops the text of a document whose current version has
+// no file and reindexes it for search.
+func (r *DocumentRepo) DeleteExtraction(ctx context.Context, id string) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Delete("document_content").
+		Where(sq.Eq{"document_id": id}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	cmd, err := tx.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return nil
+	}
+
+	if err := r.refreshSearch(ctx, tx, id); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
\ No newline at end of file
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"docs_storage/internal/models"
+)
+
+// sortColumns are the fields a listing can be ordered by. They are all NOT
+// NULL, which keyset pagination relies on.
+var sortColumns = map[string]bool{
+	"name":       true,
+	"created_at": true,
+	"mime":       true,
+}
+
+// parseSort splits a sort such as "-created_at" into its column and
+// direction. An empty sort orders by name.
+func parseSort(sort string) (string, bool, error) {
+	desc := strings.HasPrefix(sort, "-")
+	column := strings.TrimPrefix(sort, "-")
+	if column == "" {
+		column = "name"
+	}
+	if !sortColumns[column] {
+		return "", false, ErrInvalidSort
+	}
+	return column, desc, nil
+}
+
+// cursor is the position after the last document of a page. It carries the
+// sort it was made for, so that it is not applied to a differently ordered
+// listing.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeCursor(sort, value, id string) string {
+	buf, _ := json.Marshal(cursor{Sort: sort, Value: value, ID: id})
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func decodeCursor(s, sort string) (*cursor, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(buf, &c); err != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(c.ID); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+func sortValue(column string, d models.Document) string {
+	switch column {
+	case "created_at":
+		return d.CreatedAt.Format(time.RFC3339Nano)
+	case "mime":
+		return d.Mime
+	default:
+		return d.Name
+	}
+}
+
+// cursorValue converts a cursor's sort value back to what the column holds.
+func cursorValue(column, value string) (any, error) {
+	if column != "created_at" {
+		return value, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return t, nil
+}
\ No newline at end of file
@@ -2,12 +2,12 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"strconv"
-	"time"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 
@@ -17,30 +17,58 @@ import (
 var (
 	ErrNotFound     = errors.New("not found")
 	ErrAccessDenied = errors.New("access denied")
+	ErrConflict     = errors.New("document was modified concurrently")
+	ErrNameTaken    = errors.New("document name already taken")
+
+	ErrInvalidSort   = errors.New("invalid sort field")
+	ErrInvalidCursor = errors.New("invalid cursor")
 )
 
+var documentColumns = []string{
+	"id", "name", "mime", "file", "public", "owner_login",
+	"created_at", "json_data", "file_path", "version", "checksum",
+}
+
+// aclColumn selects a document's ACL as a JSON array, so that documents and
+// their entries are read in one query.
+const aclColumn = `COALESCE((
+	SELECT json_agg(json_build_object('grantee', a.grantee, 'permissions', a.permissions) ORDER BY a.grantee)
+	FROM document_acl a WHERE a.document_id = documents.id
+), '[]')`
+
+// extractionColumn selects how far text extraction from the document's file
+// has come.
+const extractionColumn = `COALESCE((
+	SELECT c.status FROM document_content c WHERE c.document_id = documents.id
+), '')`
+
+var versionColumns = []string{
+	"document_id", "version", "mime", "file", "json_data", "file_path", "created_by", "created_at", "checksum",
+}
+
 type DocumentRepo struct {
 	db *pgxpool.Pool
+	// searchLanguage is the text search configuration documents are
+	// indexed and searched with.
+	searchLanguage string
 }
 
-func NewDocsRepo(db *pgxpool.Pool) *DocumentRepo {
-	return &DocumentRepo{db: db}
+func NewDocsRepo(db *pgxpool.Pool, searchLanguage string) *DocumentRepo {
+	return &DocumentRepo{db: db, searchLanguage: searchLanguage}
 }
 
 func (r *DocumentRepo) Save(ctx context.Context, doc *models.Document) error {
 	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
+	doc.Version = 1
+
 	q := builder.
 		Insert("documents").
-		Columns(
-			"id", "name", "mime", "file", "public",
-			"owner_login", "grant_list",
-			"created_at", "json_data", "file_path",
-		).
+		Columns(documentColumns...).
 		Values(
 			doc.ID, doc.Name, doc.Mime, doc.File, doc.Public,
-			doc.OwnerLogin, doc.Grant,
-			doc.CreatedAt, doc.JSONData, doc.FilePath,
+			doc.OwnerLogin,
+			doc.CreatedAt, doc.JSONData, doc.FilePath, doc.Version, nullable(doc.Checksum),
 		)
 
 	sqlStr, args, err := q.ToSql()
@@ -48,72 +76,274 @@ func (r *DocumentRepo) Save(ctx context.Context, doc *models.Document) error {
 		return err
 	}
 
-	_, err = r.db.Exec(ctx, sqlStr, args...)
-	return err
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, sqlStr, args...); err != nil {
+		return err
+	}
+
+	v := &models.DocumentVersion{
+		DocumentID: doc.ID,
+		Version:    doc.Version,
+		Mime:       doc.Mime,
+		File:       doc.File,
+		JSONData:   doc.JSONData,
+		FilePath:   doc.FilePath,
+		Checksum:   doc.Checksum,
+		CreatedBy:  doc.OwnerLogin,
+		CreatedAt:  doc.CreatedAt,
+	}
+	if err := insertVersion(ctx, tx, v); err != nil {
+		return err
+	}
+
+	for _, e := range doc.ACL {
+		if err := upsertACLEntry(ctx, tx, doc.ID, e, doc.OwnerLogin, false); err != nil {
+			return err
+		}
+	}
+
+	if err := r.refreshSearch(ctx, tx, doc.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Share adds e to the document's ACL. Permissions the grantee already has
+// are kept, so sharing never takes access away.
+func (r *DocumentRepo) Share(ctx context.Context, id string, e models.ACLEntry, grantedBy string) error {
+	return upsertACLEntry(ctx, r.db, id, e, grantedBy, true)
+}
+
+// SetACLEntry gives the grantee exactly e.Permissions.
+func (r *DocumentRepo) SetACLEntry(ctx context.Context, id string, e models.ACLEntry, grantedBy string) error {
+	return upsertACLEntry(ctx, r.db, id, e, grantedBy, false)
+}
+
+func (r *DocumentRepo) DeleteACLEntry(ctx context.Context, id, grantee string) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Delete("document_acl").
+		Where(sq.Eq{"document_id": id, "grantee": grantee}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdateMeta changes the fields that are not nil. Names are unique, a clash
+// is reported as ErrNameTaken.
+func (r *DocumentRepo) UpdateMeta(ctx context.Context, id string, name *string, public *bool) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	fields := make(map[string]any)
+	if name != nil {
+		fields["name"] = *name
+	}
+	if public != nil {
+		fields["public"] = *public
+	}
+
+	sqlStr, args, err := builder.
+		Update("documents").
+		SetMap(fields).
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return ErrNameTaken
+		}
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	if name != nil {
+		return r.refreshSearch(ctx, r.db, id)
+	}
+	return nil
 }
 
-func (r *DocumentRepo) List(ctx context.Context, requesterLogin, login, key, value string, limit int) ([]models.Document, error) {
+func (r *DocumentRepo) AddVersion(ctx context.Context, v *models.DocumentVersion) error {
 	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
 	q := builder.
-		Select("id", "name", "mime", "file", "public", "owner_login", "grant_list", "created_at", "json_data", "file_path").
-		From("documents")
-
-	if login != "" {
-		q = q.Where(sq.Eq{"owner_login": login})
-	} else {
-		q = q.Where(sq.Or{
-			sq.Eq{"owner_login": requesterLogin},
-			sq.Eq{"public": true},
-			sq.Expr("? = ANY(grant_list)", requesterLogin),
-		})
+		Update("documents").
+		Set("mime", v.Mime).
+		Set("file", v.File).
+		Set("json_data", v.JSONData).
+		Set("file_path", v.FilePath).
+		Set("version", v.Version).
+		Set("checksum", nullable(v.Checksum)).
+		Where(sq.Eq{"id": v.DocumentID, "version": v.Version - 1})
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	cmd, err := tx.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrConflict
+	}
+
+	if err := insertVersion(ctx, tx, v); err != nil {
+		return err
+	}
+
+	if err := r.refreshSearch(ctx, tx, v.DocumentID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *DocumentRepo) ListVersions(ctx context.Context, id string) ([]models.DocumentVersion, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q := builder.
+		Select(versionColumns...).
+		From("document_versions").
+		Where(sq.Eq{"document_id": id}).
+		OrderBy("version")
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []models.DocumentVersion
+	for rows.Next() {
+		var v models.DocumentVersion
+		if err := scanVersion(rows, &v); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+
+	return versions, rows.Err()
+}
+
+func (r *DocumentRepo) GetVersion(ctx context.Context, id string, version int) (*models.DocumentVersion, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q := builder.
+		Select(versionColumns...).
+		From("document_versions").
+		Where(sq.Eq{"document_id": id, "version": version}).
+		Limit(1)
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	row := r.db.QueryRow(ctx, sqlStr, args...)
+	var v models.DocumentVersion
+	if err := scanVersion(row, &v); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &v, nil
+}
+
+// List returns one page of the documents matching f. Pages are cut by
+// keyset: the cursor holds the sort value and id of the last document of the
+// previous page, so documents added or removed meanwhile do not shift pages.
+func (r *DocumentRepo) List(ctx context.Context, f models.DocumentFilter) (*models.DocumentPage, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	where, err := documentConditions(f)
+	if err != nil {
+		return nil, err
 	}
 
-	allowedKeys := map[string]bool{
-		"id":         true,
-		"name":       true,
-		"mime":       true,
-		"file":       true,
-		"public":     true,
-		"created_at": true,
+	column, desc, err := parseSort(f.Sort)
+	if err != nil {
+		return nil, err
 	}
 
-	if key != "" && value != "" {
-		if !allowedKeys[key] {
-			return nil, fmt.Errorf("invalid filter key: %s", key)
+	page := &models.DocumentPage{}
+	if f.WithTotal {
+		sqlStr, args, err := builder.Select("COUNT(*)").From("documents").Where(where).ToSql()
+		if err != nil {
+			return nil, err
 		}
+		var total int
+		if err := r.db.QueryRow(ctx, sqlStr, args...).Scan(&total); err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
 
-		if key == "file" || key == "public" {
-			b, err := strconv.ParseBool(value)
-			if err != nil {
-				return nil, fmt.Errorf("invalid boolean value for %s: %w", key, err)
-			}
-			q = q.Where(sq.Eq{key: b})
-		} else if key == "created_at" {
-			var parsed time.Time
-			var err error
-			formats := []string{
-				time.RFC3339, "2006-01-02", "2006-01-02 15:04:05",
-			}
-			for _, f := range formats {
-				parsed, err = time.Parse(f, value)
-				if err == nil {
-					break
-				}
-			}
-			if err == nil {
-				q = q.Where(sq.Eq{key: parsed})
-			} else {
-				q = q.Where(sq.Eq{key: value})
-			}
-		} else {
-			q = q.Where(sq.Eq{key: value})
+	q := builder.
+		Select(documentColumns...).
+		Column(aclColumn).
+		Column(extractionColumn).
+		From("documents").
+		Where(where)
+
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor, f.Sort)
+		if err != nil {
+			return nil, err
+		}
+		value, err := cursorValue(column, c.Value)
+		if err != nil {
+			return nil, err
+		}
+		op := ">"
+		if desc {
+			op = "<"
 		}
+		q = q.Where(sq.Expr(fmt.Sprintf("(%s, id) %s (?, ?)", column, op), value, c.ID))
 	}
 
-	q = q.OrderBy("name", "created_at")
-	if limit > 0 {
-		q = q.Limit(uint64(limit))
+	order := "ASC"
+	if desc {
+		order = "DESC"
+	}
+	q = q.OrderBy(column+" "+order, "id "+order)
+	if f.Limit > 0 {
+		// One extra row tells whether there is a next page.
+		q = q.Limit(uint64(f.Limit) + 1)
 	}
 
 	sqlStr, args, err := q.ToSql()
@@ -127,26 +357,64 @@ func (r *DocumentRepo) List(ctx context.Context, requesterLogin, login, key, val
 	}
 	defer rows.Close()
 
-	var docs []models.Document
 	for rows.Next() {
 		var d models.Document
-		if err := rows.Scan(
-			&d.ID, &d.Name, &d.Mime, &d.File, &d.Public,
-			&d.OwnerLogin, &d.Grant, &d.CreatedAt, &d.JSONData, &d.FilePath,
-		); err != nil {
+		if err := scanDocument(rows, &d); err != nil {
 			return nil, err
 		}
-		docs = append(docs, d)
+		page.Docs = append(page.Docs, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if f.Limit > 0 && len(page.Docs) > f.Limit {
+		page.Docs = page.Docs[:f.Limit]
+		last := page.Docs[f.Limit-1]
+		page.NextCursor = encodeCursor(f.Sort, sortValue(column, last), last.ID)
+	}
+	return page, nil
+}
+
+// documentConditions turns the filter into the WHERE clause shared by a
+// listing and its count.
+func documentConditions(f models.DocumentFilter) (sq.And, error) {
+	where := sq.And{}
+
+	if f.PublicOnly {
+		where = append(where, sq.Eq{"public": true})
+	} else if !f.All {
+		where = append(where, sq.Or{
+			sq.Eq{"owner_login": f.RequesterLogin},
+			sq.Eq{"public": true},
+			sq.Expr("EXISTS (SELECT 1 FROM document_acl a WHERE a.document_id = documents.id AND a.grantee = ANY(?))", f.RequesterPrincipals),
+		})
+	}
+	if f.Login != "" {
+		where = append(where, sq.Eq{"owner_login": f.Login})
+	}
+
+	conds := f.Filters
+	// The single key/value pair predates filters and means equality.
+	if f.Key != "" && f.Value != "" {
+		conds = append([]models.DocumentCondition{{Field: f.Key, Op: "eq", Value: f.Value}}, conds...)
+	}
+	filters, err := compileFilters(conds)
+	if err != nil {
+		return nil, err
 	}
+	where = append(where, filters...)
 
-	return docs, nil
+	return where, nil
 }
 
 func (r *DocumentRepo) GetByID(ctx context.Context, id string) (*models.Document, error) {
 	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
 	q := builder.
-		Select("id", "name", "mime", "file", "public", "owner_login", "grant_list", "created_at", "json_data", "file_path").
+		Select(documentColumns...).
+		Column(aclColumn).
+		Column(extractionColumn).
 		From("documents").
 		Where(sq.Eq{"id": id}).
 		Limit(1)
@@ -158,10 +426,7 @@ func (r *DocumentRepo) GetByID(ctx context.Context, id string) (*models.Document
 
 	row := r.db.QueryRow(ctx, sqlStr, args...)
 	var d models.Document
-	if err := row.Scan(
-		&d.ID, &d.Name, &d.Mime, &d.File, &d.Public,
-		&d.OwnerLogin, &d.Grant, &d.CreatedAt, &d.JSONData, &d.FilePath,
-	); err != nil {
+	if err := scanDocument(row, &d); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
@@ -189,3 +454,119 @@ func (r *DocumentRepo) Delete(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+func (r *DocumentRepo) ReassignOwner(ctx context.Context, from, to string) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q := builder.
+		Update("documents").
+		Set("owner_login", to).
+		Where(sq.Eq{"owner_login": from})
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, sqlStr, args...)
+	return err
+}
+
+// execer is satisfied by both the pool and a transaction.
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+}
+
+// upsertACLEntry adds or replaces the grantee's entry. With merge set, the
+// permissions it had already are kept.
+func upsertACLEntry(ctx context.Context, db execer, id string, e models.ACLEntry, grantedBy string, merge bool) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	permissions := "EXCLUDED.permissions"
+	if merge {
+		permissions = "ARRAY(SELECT unnest(document_acl.permissions) UNION SELECT unnest(EXCLUDED.permissions) ORDER BY 1)"
+	}
+
+	q := builder.
+		Insert("document_acl").
+		Columns("document_id", "grantee", "permissions", "granted_by").
+		Values(id, e.Grantee, e.Permissions, grantedBy).
+		Suffix("ON CONFLICT (document_id, grantee) DO UPDATE SET permissions = " + permissions + ", granted_by = EXCLUDED.granted_by")
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(ctx, sqlStr, args...)
+	return err
+}
+
+func insertVersion(ctx context.Context, tx pgx.Tx, v *models.DocumentVersion) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q := builder.
+		Insert("document_versions").
+		Columns(versionColumns...).
+		Values(
+			v.DocumentID, v.Version, v.Mime, v.File, v.JSONData,
+			v.FilePath, v.CreatedBy, v.CreatedAt, nullable(v.Checksum),
+		)
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, sqlStr, args...)
+	return err
+}
+
+func scanDocument(row pgx.Row, d *models.Document) error {
+	var filePath, checksum *string
+	var acl []byte
+	if err := row.Scan(
+		&d.ID, &d.Name, &d.Mime, &d.File, &d.Public,
+		&d.OwnerLogin, &d.CreatedAt, &d.JSONData, &filePath, &d.Version, &checksum, &acl,
+		&d.ExtractionStatus,
+	); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(acl, &d.ACL); err != nil {
+		return err
+	}
+	if filePath != nil {
+		d.FilePath = *filePath
+	}
+	if checksum != nil {
+		d.Checksum = *checksum
+	}
+	return nil
+}
+
+func scanVersion(row pgx.Row, v *models.DocumentVersion) error {
+	var filePath, createdBy, checksum *string
+	if err := row.Scan(
+		&v.DocumentID, &v.Version, &v.Mime, &v.File, &v.JSONData,
+		&filePath, &createdBy, &v.CreatedAt, &checksum,
+	); err != nil {
+		return err
+	}
+	if checksum != nil {
+		v.Checksum = *checksum
+	}
+	if filePath != nil {
+		v.FilePath = *filePath
+	}
+	if createdBy != nil {
+		v.CreatedBy = *createdBy
+	}
+	return nil
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+
+	"docs_storage/internal/models"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// maxFilterConditions bounds the size of the WHERE clause a client can make
+// us build.
+const maxFilterConditions = 20
+
+type fieldType int
+
+const (
+	fieldText fieldType = iota
+	fieldUUID
+	fieldBool
+	fieldTime
+)
+
+// filterFields are the document columns listings can be filtered on.
+var filterFields = map[string]fieldType{
+	"id":         fieldUUID,
+	"name":       fieldText,
+	"mime":       fieldText,
+	"file":       fieldBool,
+	"public":     fieldBool,
+	"created_at": fieldTime,
+}
+
+// filterTimeFormats are tried in order for created_at values.
+var filterTimeFormats = []string{
+	time.RFC3339, "2006-01-02", "2006-01-02 15:04:05",
+}
+
+// compileFilters turns the conditions into squirrel expressions, checking
+// every field, operator and value on the way.
+func compileFilters(conds []models.DocumentCondition) (sq.And, error) {
+	if len(conds) > maxFilterConditions {
+		return nil, fmt.Errorf("%w: at most %d conditions are allowed", ErrInvalidFilter, maxFilterConditions)
+	}
+
+	where := make(sq.And, 0, len(conds))
+	for _, c := range conds {
+		expr, err := compileCondition(c)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, expr)
+	}
+	return where, nil
+}
+
+func compileCondition(c models.DocumentCondition) (sq.Sqlizer, error) {
+	if strings.HasPrefix(c.Field, models.JSONFieldPrefix) {
+		return compileJSONCondition(c)
+	}
+
+	typ, ok := filterFields[c.Field]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidFilter, c.Field)
+	}
+
+	switch c.Op {
+	case "eq", "ne":
+		v, err := parseFilterValue(c.Field, typ, c.Value)
+		if err != nil {
+			return nil, err
+		}
+		if c.Op == "eq" {
+			return sq.Eq{c.Field: v}, nil
+		}
+		return sq.NotEq{c.Field: v}, nil
+
+	case "lt", "lte", "gt", "gte":
+		if typ == fieldBool {
+			return nil, fmt.Errorf("%w: %s cannot be compared with %s", ErrInvalidFilter, c.Field, c.Op)
+		}
+		v, err := parseFilterValue(c.Field, typ, c.Value)
+		if err != nil {
+			return nil, err
+		}
+		switch c.Op {
+		case "lt":
+			return sq.Lt{c.Field: v}, nil
+		case "lte":
+			return sq.LtOrEq{c.Field: v}, nil
+		case "gt":
+			return sq.Gt{c.Field: v}, nil
+		default:
+			return sq.GtOrEq{c.Field: v}, nil
+		}
+
+	case "in":
+		parts := strings.Split(c.Value, ",")
+		values := make([]any, 0, len(parts))
+		for _, p := range parts {
+			v, err := parseFilterValue(c.Field, typ, p)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return sq.Eq{c.Field: values}, nil
+
+	case "prefix", "contains":
+		if typ != fieldText {
+			return nil, fmt.Errorf("%w: %s does not support %s", ErrInvalidFilter, c.Field, c.Op)
+		}
+		pattern := escapeLike(c.Value) + "%"
+		if c.Op == "contains" {
+			pattern = "%" + pattern
+		}
+		return sq.Like{c.Field: pattern}, nil
+
+	default:
+		return nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidFilter, c.Op)
+	}
+}
+
+func parseFilterValue(field string, typ fieldType, value string) (any, error) {
+	switch typ {
+	case fieldUUID:
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be a UUID", ErrInvalidFilter, field)
+		}
+		return id.String(), nil
+	case fieldBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s must be true or false", ErrInvalidFilter, field)
+		}
+		return b, nil
+	case fieldTime:
+		for _, f := range filterTimeFormats {
+			if t, err := time.Parse(f, value); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s must be a date or RFC 3339 time", ErrInvalidFilter, field)
+	default:
+		return value, nil
+	}
+}
+
+// escapeLike makes value match literally inside a LIKE pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
\ No newline at end of file
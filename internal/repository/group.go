@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"docs_storage/internal/models"
+)
+
+var ErrGroupExists = errors.New("group already exists")
+
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+// localGroupsColumn selects the names of the local groups of the user in the
+// users row referred to as table.
+func localGroupsColumn(table string) string {
+	return fmt.Sprintf(
+		"ARRAY(SELECT g.name FROM group_members m JOIN groups g ON g.id = m.group_id WHERE m.user_id = %s.id ORDER BY g.name)",
+		table,
+	)
+}
+
+type GroupRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewGroupRepo(db *pgxpool.Pool) *GroupRepo {
+	return &GroupRepo{db: db}
+}
+
+func (r *GroupRepo) Create(ctx context.Context, g *models.Group) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Insert("groups").
+		Columns("name", "description").
+		Values(g.Name, g.Description).
+		Suffix("RETURNING id, created_at").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	if err := r.db.QueryRow(ctx, sqlStr, args...).Scan(&g.ID, &g.CreatedAt); err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return ErrGroupExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *GroupRepo) List(ctx context.Context) ([]models.Group, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Select("g.id", "g.name", "g.description", "g.created_at").
+		Column("(SELECT COUNT(*) FROM group_members m WHERE m.group_id = g.id)").
+		From("groups g").
+		OrderBy("g.name").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []models.Group
+	for rows.Next() {
+		var g models.Group
+		if err := rows.Scan(&g.ID, &g.Name, &g.Description, &g.CreatedAt, &g.MemberCount); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+// GetByID returns the group with its member logins, or nil if there is no
+// such group.
+func (r *GroupRepo) GetByID(ctx context.Context, id int) (*models.Group, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Select("g.id", "g.name", "g.description", "g.created_at").
+		Column("ARRAY(SELECT u.login FROM group_members m JOIN users u ON u.id = m.user_id WHERE m.group_id = g.id ORDER BY u.login)").
+		From("groups g").
+		Where(sq.Eq{"g.id": id}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var g models.Group
+	if err := r.db.QueryRow(ctx, sqlStr, args...).Scan(&g.ID, &g.Name, &g.Description, &g.CreatedAt, &g.Members); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	g.MemberCount = len(g.Members)
+	return &g, nil
+}
+
+// Update renames the group and changes its description. ACL entries that
+// name the group are rewritten in the same transaction, so that renaming does
+// not revoke access.
+func (r *GroupRepo) Update(ctx context.Context, g *models.Group) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var oldName string
+	sqlStr, args, err := builder.
+		Select("name").
+		From("groups").
+		Where(sq.Eq{"id": g.ID}).
+		Suffix("FOR UPDATE").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if err := tx.QueryRow(ctx, sqlStr, args...).Scan(&oldName); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	sqlStr, args, err = builder.
+		Update("groups").
+		SetMap(map[string]any{"name": g.Name, "description": g.Description}).
+		Where(sq.Eq{"id": g.ID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, sqlStr, args...); err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return ErrGroupExists
+		}
+		return err
+	}
+
+	if oldName != g.Name {
+		sqlStr, args, err = builder.
+			Update("document_acl").
+			Set("grantee", models.GroupPrincipal(g.Name)).
+			Where(sq.Eq{"grantee": models.GroupPrincipal(oldName)}).
+			ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, sqlStr, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Delete removes the group and every ACL entry granted to it.
+func (r *GroupRepo) Delete(ctx context.Context, id int) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var name string
+	sqlStr, args, err := builder.
+		Delete("groups").
+		Where(sq.Eq{"id": id}).
+		Suffix("RETURNING name").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if err := tx.QueryRow(ctx, sqlStr, args...).Scan(&name); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	sqlStr, args, err = builder.
+		Delete("document_acl").
+		Where(sq.Eq{"grantee": models.GroupPrincipal(name)}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, sqlStr, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// AddMember is a no-op if the user is a member already. It returns
+// ErrNotFound if either the group or the user does not exist.
+func (r *GroupRepo) AddMember(ctx context.Context, groupID, userID int) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Insert("group_members").
+		Columns("group_id", "user_id").
+		Values(groupID, userID).
+		Suffix("ON CONFLICT DO NOTHING").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.db.Exec(ctx, sqlStr, args...); err != nil {
+		if isPgError(err, pgForeignKeyViolation) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *GroupRepo) RemoveMember(ctx context.Context, groupID, userID int) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Delete("group_members").
+		Where(sq.Eq{"group_id": groupID, "user_id": userID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func isPgError(err error, code string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == code
+}
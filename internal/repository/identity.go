@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"docs_storage/internal/models"
+)
+
+var (
+	ErrUserExists    = errors.New("user already exists")
+	ErrIdentityTaken = errors.New("identity is linked to another user")
+)
+
+// GetByIdentity returns the user the subject at the identity provider issuer
+// is linked to, or nil.
+func (r *UserRepo) GetByIdentity(ctx context.Context, issuer, subject string) (*models.User, error) {
+	return r.getBy(ctx, sq.Expr(
+		"id = (SELECT user_id FROM user_identities WHERE issuer = ? AND subject = ?)", issuer, subject,
+	))
+}
+
+// CreateWithIdentity creates a user linked to the subject at issuer in one
+// step. A login or identity that is already in use is reported as
+// ErrUserExists.
+func (r *UserRepo) CreateWithIdentity(ctx context.Context, u *models.User, issuer, subject string) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	sqlStr, args, err := builder.
+		Insert("users").
+		Columns("login", "password_hash", "role", "auth_source").
+		Values(u.Login, u.Password, u.Role, authSource(u)).
+		Suffix("RETURNING id").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if err := tx.QueryRow(ctx, sqlStr, args...).Scan(&u.ID); err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return ErrUserExists
+		}
+		return err
+	}
+
+	sqlStr, args, err = builder.
+		Insert("user_identities").
+		Columns("issuer", "subject", "user_id").
+		Values(issuer, subject, u.ID).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, sqlStr, args...); err != nil {
+		if isPgError(err, pgUniqueViolation) {
+			return ErrUserExists
+		}
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// LinkIdentity lets the subject at issuer log in as the user. Linking it
+// again to the same user is not an error.
+func (r *UserRepo) LinkIdentity(ctx context.Context, userID int, issuer, subject string) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Insert("user_identities").
+		Columns("issuer", "subject", "user_id").
+		Values(issuer, subject, userID).
+		Suffix(`ON CONFLICT (issuer, subject) DO UPDATE SET user_id = EXCLUDED.user_id
+			WHERE user_identities.user_id = EXCLUDED.user_id`).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		if isPgError(err, pgForeignKeyViolation) {
+			return ErrNotFound
+		}
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrIdentityTaken
+	}
+	return nil
+}
+
+// UnlinkIdentities removes every identity at issuer linked to the user.
+func (r *UserRepo) UnlinkIdentities(ctx context.Context, userID int, issuer string) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Delete("user_identities").
+		Where(sq.Eq{"user_id": userID, "issuer": issuer}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
\ No newline at end of file
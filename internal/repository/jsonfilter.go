@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"docs_storage/internal/models"
+)
+
+const maxJSONPathDepth = 10
+
+var jsonComparisons = map[string]string{
+	"lt":  "<",
+	"lte": "<=",
+	"gt":  ">",
+	"gte": ">=",
+}
+
+// compileJSONCondition filters on a value inside json_data. Equality
+// compiles to containment, which the GIN index on json_data serves; the
+// other operators cannot use it.
+func compileJSONCondition(c models.DocumentCondition) (sq.Sqlizer, error) {
+	path, err := parseJSONPath(strings.TrimPrefix(c.Field, models.JSONFieldPrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Op {
+	case "eq":
+		return jsonEquals(path, c.Value), nil
+
+	case "ne":
+		// Documents without the field, or without JSON at all, count as
+		// different.
+		return sq.Expr("NOT COALESCE((?), false)", jsonEquals(path, c.Value)), nil
+
+	case "in":
+		or := sq.Or{}
+		for _, v := range strings.Split(c.Value, ",") {
+			or = append(or, jsonEquals(path, v))
+		}
+		return or, nil
+
+	case "lt", "lte", "gt", "gte":
+		// Numbers compare as numbers and anything else as strings; a value
+		// of the other type never matches.
+		v, ok := jsonScalar(c.Value)
+		if !ok {
+			v, _ = json.Marshal(c.Value)
+		}
+		vars, _ := json.Marshal(map[string]json.RawMessage{"v": v})
+		expr := fmt.Sprintf("%s ? (@ %s $v)", jsonPathExpr(path), jsonComparisons[c.Op])
+		return sq.Expr("jsonb_path_exists(json_data, ?::jsonpath, ?::jsonb)", expr, string(vars)), nil
+
+	case "prefix", "contains":
+		pattern := escapeLike(c.Value) + "%"
+		if c.Op == "contains" {
+			pattern = "%" + pattern
+		}
+		return sq.Expr("json_data #>> ? LIKE ?", path, pattern), nil
+
+	default:
+		return nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidFilter, c.Op)
+	}
+}
+
+func parseJSONPath(s string) ([]string, error) {
+	path := strings.Split(s, ".")
+	if len(path) > maxJSONPathDepth {
+		return nil, fmt.Errorf("%w: json paths are at most %d keys deep", ErrInvalidFilter, maxJSONPathDepth)
+	}
+	for _, key := range path {
+		if key == "" {
+			return nil, fmt.Errorf("%w: empty key in json path %q", ErrInvalidFilter, s)
+		}
+	}
+	return path, nil
+}
+
+// jsonEquals matches documents whose value at path is value. Query strings
+// carry no types, so a value that reads as a JSON number, boolean or null
+// also matches that.
+func jsonEquals(path []string, value string) sq.Sqlizer {
+	str, _ := json.Marshal(value)
+	or := sq.Or{sq.Expr("json_data @> ?::jsonb", jsonNest(path, str))}
+	if v, ok := jsonScalar(value); ok {
+		or = append(or, sq.Expr("json_data @> ?::jsonb", jsonNest(path, v)))
+	}
+	return or
+}
+
+// jsonScalar returns value as JSON if it is a number, boolean or null.
+func jsonScalar(value string) (json.RawMessage, bool) {
+	var v any
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return nil, false
+	}
+	switch v.(type) {
+	case float64, bool, nil:
+		return json.RawMessage(value), true
+	default:
+		return nil, false
+	}
+}
+
+// jsonNest wraps v in one object per key of path, innermost last.
+func jsonNest(path []string, v json.RawMessage) string {
+	for i := len(path) - 1; i >= 0; i-- {
+		v, _ = json.Marshal(map[string]json.RawMessage{path[i]: v})
+	}
+	return string(v)
+}
+
+// jsonPathExpr spells path as an SQL/JSON path with every key quoted.
+func jsonPathExpr(path []string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, key := range path {
+		quoted, _ := json.Marshal(key)
+		b.WriteString(".")
+		b.Write(quoted)
+	}
+	return b.String()
+}
\ No newline at end of file
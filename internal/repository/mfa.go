@@ -0,0 +1,283 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"docs_storage/internal/models"
+)
+
+// MFARepo stores TOTP secrets, recovery codes and pending MFA challenges.
+// Recovery codes and challenge tokens are kept hashed, like session tokens.
+type MFARepo struct {
+	db           *pgxpool.Pool
+	challengeTTL time.Duration
+}
+
+func NewMFARepo(db *pgxpool.Pool, challengeTTL time.Duration) *MFARepo {
+	return &MFARepo{db: db, challengeTTL: challengeTTL}
+}
+
+// GetTOTP returns nil if the user has not enrolled.
+func (r *MFARepo) GetTOTP(ctx context.Context, userID int) (*models.TOTP, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Select("id", "totp_secret", "totp_enabled", "totp_last_step").
+		From("users").
+		Where(sq.Eq{"id": userID}).
+		Where(sq.NotEq{"totp_secret": nil}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var t models.TOTP
+	if err := r.db.QueryRow(ctx, sqlStr, args...).Scan(&t.UserID, &t.Secret, &t.Enabled, &t.LastStep); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// SetTOTPSecret starts a new enrollment, replacing any earlier one that was
+// not confirmed.
+func (r *MFARepo) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	return r.updateUser(ctx, userID, map[string]any{
+		"totp_secret":    secret,
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+	})
+}
+
+// EnableTOTP turns the second factor on and replaces the recovery codes.
+func (r *MFARepo) EnableTOTP(ctx context.Context, userID int, recoveryCodes []string) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	sqlStr, args, err := builder.
+		Update("users").
+		Set("totp_enabled", true).
+		Where(sq.Eq{"id": userID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, sqlStr, args...); err != nil {
+		return err
+	}
+
+	sqlStr, args, err = builder.Delete("recovery_codes").Where(sq.Eq{"user_id": userID}).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, sqlStr, args...); err != nil {
+		return err
+	}
+
+	if len(recoveryCodes) > 0 {
+		q := builder.Insert("recovery_codes").Columns("user_id", "code_hash")
+		for _, code := range recoveryCodes {
+			q = q.Values(userID, hashToken(code))
+		}
+		sqlStr, args, err = q.ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, sqlStr, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ResetTOTP removes the second factor together with its recovery codes and
+// any logins waiting for it.
+func (r *MFARepo) ResetTOTP(ctx context.Context, userID int) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	sqlStr, args, err := builder.
+		Update("users").
+		SetMap(map[string]any{"totp_secret": nil, "totp_enabled": false, "totp_last_step": 0}).
+		Where(sq.Eq{"id": userID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	cmd, err := tx.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	for _, table := range []string{"recovery_codes", "mfa_challenges"} {
+		sqlStr, args, err = builder.Delete(table).Where(sq.Eq{"user_id": userID}).ToSql()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, sqlStr, args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// AdvanceTOTPStep records that a code for step was used. It reports false if
+// that step or a later one was used already, i.e. the code is a replay.
+func (r *MFARepo) AdvanceTOTPStep(ctx context.Context, userID int, step int64) (bool, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Update("users").
+		Set("totp_last_step", step).
+		Where(sq.Eq{"id": userID}).
+		Where(sq.Lt{"totp_last_step": step}).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode consumes a recovery code, reporting whether it was valid.
+func (r *MFARepo) UseRecoveryCode(ctx context.Context, userID int, code string) (bool, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Delete("recovery_codes").
+		Where(sq.Eq{"user_id": userID, "code_hash": hashToken(code)}).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() == 1, nil
+}
+
+func (r *MFARepo) CreateChallenge(ctx context.Context, token string, userID int) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Insert("mfa_challenges").
+		Columns("token_hash", "user_id").
+		Values(hashToken(token), userID).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, sqlStr, args...)
+	return err
+}
+
+// AttemptChallenge counts an attempt to answer the challenge and returns its
+// user together with the number of attempts so far, this one included. It
+// returns 0 for unknown or expired challenges.
+func (r *MFARepo) AttemptChallenge(ctx context.Context, token string) (userID, attempts int, err error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Update("mfa_challenges").
+		Set("attempts", sq.Expr("attempts + 1")).
+		Where(sq.Eq{"token_hash": hashToken(token)}).
+		Where(sq.Expr("created_at >= NOW() - make_interval(secs => ?)", r.challengeTTL.Seconds())).
+		Suffix("RETURNING user_id, attempts").
+		ToSql()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := r.db.QueryRow(ctx, sqlStr, args...).Scan(&userID, &attempts); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	return userID, attempts, nil
+}
+
+func (r *MFARepo) DeleteChallenge(ctx context.Context, token string) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Delete("mfa_challenges").
+		Where(sq.Eq{"token_hash": hashToken(token)}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, sqlStr, args...)
+	return err
+}
+
+func (r *MFARepo) DeleteExpiredChallenges(ctx context.Context) (int64, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Delete("mfa_challenges").
+		Where(sq.Expr("created_at < NOW() - make_interval(secs => ?)", r.challengeTTL.Seconds())).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+func (r *MFARepo) updateUser(ctx context.Context, userID int, fields map[string]any) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Update("users").
+		SetMap(fields).
+		Where(sq.Eq{"id": userID}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
\ No newline at end of file
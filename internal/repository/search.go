@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+
+	"docs_storage/internal/models"
+)
+
+// searchLanguages are the text search configurations documents can be
+// indexed with.
+var searchLanguages = map[string]bool{
+	"russian": true,
+	"english": true,
+	"simple":  true,
+}
+
+func ValidSearchLanguage(lang string) bool {
+	return searchLanguages[lang]
+}
+
+// searchVectorExpr builds a document's search vector. Matches in the name
+// rank above matches in the string values of json_data, which rank above
+// matches in the text extracted from the file. Every placeholder takes the
+// text search configuration.
+const searchVectorExpr = `setweight(to_tsvector(?::regconfig, documents.name), 'A') ||
+	setweight(jsonb_to_tsvector(?::regconfig, COALESCE(documents.json_data, '{}'), '["string"]'), 'B') ||
+	setweight(to_tsvector(?::regconfig, COALESCE(` + contentTextExpr + `, '')), 'C')`
+
+// contentTextExpr is the text extracted from the document's file. Text of
+// an older version stays until that of the current one replaces it.
+const contentTextExpr = `(SELECT c.text FROM document_content c WHERE c.document_id = documents.id)`
+
+// searchTextExpr is the text snippets are cut from: the name, the string
+// values of json_data and the extracted text. The jsonpath filter's "?" is
+// doubled so that it is not taken for a placeholder.
+const searchTextExpr = `concat_ws(' ', documents.name, (
+	SELECT string_agg(v #>> '{}', ' ')
+	FROM jsonb_path_query(COALESCE(documents.json_data, '{}'), 'strict $.** ?? (@.type() == "string")') AS v
+), ` + contentTextExpr + `)`
+
+// Matches are marked with control characters in the headline, so that the
+// rest of the snippet can be escaped before they become <mark> tags.
+const (
+	headlineStart   = "\x02"
+	headlineStop    = "\x03"
+	headlineOptions = "StartSel=" + headlineStart + ", StopSel=" + headlineStop + ", MaxFragments=2, MaxWords=20, MinWords=5"
+)
+
+func (r *DocumentRepo) searchVector() sq.Sqlizer {
+	return sq.Expr(searchVectorExpr, r.searchLanguage, r.searchLanguage, r.searchLanguage)
+}
+
+// refreshSearch rebuilds the search vector of one document after its name or
+// content changed.
+func (r *DocumentRepo) refreshSearch(ctx context.Context, db execer, id string) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Update("documents").
+		Set("search_vector", r.searchVector()).
+		Set("search_language", r.searchLanguage).
+		Where(sq.Eq{"id": id}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(ctx, sqlStr, args...)
+	return err
+}
+
+// ReindexSearch rebuilds the search vectors that are missing or were made
+// with another language than the configured one, and reports how many.
+func (r *DocumentRepo) ReindexSearch(ctx context.Context) (int64, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Update("documents").
+		Set("search_vector", r.searchVector()).
+		Set("search_language", r.searchLanguage).
+		Where(sq.Expr("search_language IS DISTINCT FROM ?", r.searchLanguage)).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+// Search returns the documents matching text that f lets the requester see,
+// best match first. text is read like a web search box: words, "quoted
+// phrases", OR and -excluded words.
+func (r *DocumentRepo) Search(ctx context.Context, f models.DocumentFilter, text string) ([]models.SearchResult, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	where, err := documentConditions(f)
+	if err != nil {
+		return nil, err
+	}
+
+	q := builder.
+		Select(documentColumns...).
+		Column(aclColumn).
+		Column(extractionColumn).
+		Column(sq.Alias(sq.Expr("ts_rank(documents.search_vector, query)"), "rank")).
+		Column(sq.Expr(fmt.Sprintf("ts_headline(?::regconfig, %s, query, ?)", searchTextExpr), r.searchLanguage, headlineOptions)).
+		From("documents").
+		JoinClause("CROSS JOIN websearch_to_tsquery(?::regconfig, ?) AS query", r.searchLanguage, text).
+		Where("documents.search_vector @@ query").
+		Where(where).
+		OrderBy("rank DESC", "id")
+	if f.Limit > 0 {
+		q = q.Limit(uint64(f.Limit))
+	}
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []models.SearchResult
+	for rows.Next() {
+		var res models.SearchResult
+		var headline string
+		if err := scanDocument(extraScan{rows, []any{&res.Rank, &headline}}, &res.Document); err != nil {
+			return nil, err
+		}
+		res.Snippet = strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>").Replace(html.EscapeString(headline))
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
+
+// extraScan lets scanDocument read rows that carry more columns after the
+// document's own.
+type extraScan struct {
+	pgx.Row
+	extra []any
+}
+
+func (s extraScan) Scan(dest ...any) error {
+	return s.Row.Scan(append(dest, s.extra...)...)
+}
\ No newline at end of file
@@ -2,7 +2,10 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/jackc/pgx/v4"
@@ -11,12 +14,19 @@ import (
 	"docs_storage/internal/models"
 )
 
+var ErrSessionExpired = errors.New("session expired")
+
+// SessionRepo stores login sessions. A session expires ttl after it was
+// created or idleTimeout after it was last used, whichever comes first; a
+// zero duration disables the corresponding limit.
 type SessionRepo struct {
-	db *pgxpool.Pool
+	db          *pgxpool.Pool
+	ttl         time.Duration
+	idleTimeout time.Duration
 }
 
-func NewSessionRepo(db *pgxpool.Pool) *SessionRepo {
-	return &SessionRepo{db: db}
+func NewSessionRepo(db *pgxpool.Pool, ttl, idleTimeout time.Duration) *SessionRepo {
+	return &SessionRepo{db: db, ttl: ttl, idleTimeout: idleTimeout}
 }
 
 func (r *SessionRepo) Create(ctx context.Context, s *models.Session) error {
@@ -24,8 +34,8 @@ func (r *SessionRepo) Create(ctx context.Context, s *models.Session) error {
 
     q := builder.
         Insert("sessions").
-        Columns("token", "user_id", "login").
-        Values(s.Token, s.UserID, s.Login)
+        Columns("token_hash", "user_id", "login").
+        Values(hashToken(s.Token), s.UserID, s.Login)
 
     sqlStr, args, err := q.ToSql()
     if err != nil {
@@ -40,9 +50,11 @@ func (r *SessionRepo) GetByToken(ctx context.Context, token string) (*models.Ses
     builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
     q := builder.
-        Select("token", "user_id", "login", "created_at").
-        From("sessions").
-        Where(sq.Eq{"token": token}).
+        Select("s.user_id", "s.login", "u.role", localGroupsColumn("u"), "u.external_groups", "s.created_at", "s.last_seen_at").
+        Column(r.expired("s.")).
+        From("sessions s").
+        Join("users u ON u.id = s.user_id").
+        Where(sq.Eq{"s.token_hash": hashToken(token), "u.disabled": false}).
         Limit(1)
 
     sqlStr, args, err := q.ToSql()
@@ -51,22 +63,103 @@ func (r *SessionRepo) GetByToken(ctx context.Context, token string) (*models.Ses
     }
 
     row := r.db.QueryRow(ctx, sqlStr, args...)
-    var s models.Session
-    if err := row.Scan(&s.Token, &s.UserID, &s.Login, &s.CreatedAt); err != nil {
+    s := models.Session{Token: token}
+    var expired bool
+    if err := row.Scan(&s.UserID, &s.Login, &s.Role, &s.Groups, &s.DirectoryGroups, &s.CreatedAt, &s.LastSeenAt, &expired); err != nil {
         if errors.Is(err, pgx.ErrNoRows) {
             return nil, nil
         }
         return nil, err
     }
+
+    if expired {
+        if err := r.Delete(ctx, token); err != nil {
+            return nil, err
+        }
+        return nil, ErrSessionExpired
+    }
+
+    sqlStr, args, err = builder.
+        Update("sessions").
+        Set("last_seen_at", sq.Expr("NOW()")).
+        Where(sq.Eq{"token_hash": hashToken(token)}).
+        ToSql()
+    if err != nil {
+        return nil, err
+    }
+    if _, err := r.db.Exec(ctx, sqlStr, args...); err != nil {
+        return nil, err
+    }
+
     return &s, nil
 }
 
+// Rotate replaces the session identified by oldToken with s in one step. It
+// returns ErrNotFound if the old session is already gone, so a token can be
+// rotated only once even under concurrent requests.
+func (r *SessionRepo) Rotate(ctx context.Context, oldToken string, s *models.Session) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	sqlStr, args, err := builder.
+		Delete("sessions").
+		Where(sq.Eq{"token_hash": hashToken(oldToken)}).
+		Suffix("RETURNING created_at").
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if err := tx.QueryRow(ctx, sqlStr, args...).Scan(&s.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	// The new session keeps the creation time of the old one, so that
+	// refreshing does not push back the absolute expiry.
+	sqlStr, args, err = builder.
+		Insert("sessions").
+		Columns("token_hash", "user_id", "login", "created_at").
+		Values(hashToken(s.Token), s.UserID, s.Login, s.CreatedAt).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, sqlStr, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
 func (r *SessionRepo) Delete(ctx context.Context, token string) error {
 	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
 	q := builder.
 		Delete("sessions").
-		Where(sq.Eq{"token": token})
+		Where(sq.Eq{"token_hash": hashToken(token)})
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.Exec(ctx, sqlStr, args...)
+	return err
+}
+
+func (r *SessionRepo) DeleteByUser(ctx context.Context, userID int) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q := builder.
+		Delete("sessions").
+		Where(sq.Eq{"user_id": userID})
 
 	sqlStr, args, err := q.ToSql()
 	if err != nil {
@@ -76,3 +169,41 @@ func (r *SessionRepo) Delete(ctx context.Context, token string) error {
 	_, err = r.db.Exec(ctx, sqlStr, args...)
 	return err
 }
+
+func (r *SessionRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Delete("sessions").
+		Where(r.expired("")).
+		ToSql()
+	if err != nil {
+		return 0, err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+// expired builds the expiry condition against the database clock, so that it
+// agrees with the NOW() defaults the timestamps were written with.
+func (r *SessionRepo) expired(prefix string) sq.Sqlizer {
+	cond := sq.Or{sq.Expr("false")}
+	if r.ttl > 0 {
+		cond = append(cond, sq.Expr(prefix+"created_at < NOW() - make_interval(secs => ?)", r.ttl.Seconds()))
+	}
+	if r.idleTimeout > 0 {
+		cond = append(cond, sq.Expr(prefix+"last_seen_at < NOW() - make_interval(secs => ?)", r.idleTimeout.Seconds()))
+	}
+	return cond
+}
+
+// hashToken is what gets stored instead of the bearer token itself, so that a
+// leaked sessions table cannot be used to impersonate anyone.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
\ No newline at end of file
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+
+	"docs_storage/internal/models"
+)
+
+var shareLinkColumns = []string{
+	"l.id", "l.document_id", "d.name", "l.created_by", "l.expires_at",
+	"COALESCE(l.password_hash, '')", "l.max_downloads", "l.downloads", "l.created_at",
+}
+
+type ShareLinkRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewShareLinkRepo(db *pgxpool.Pool) *ShareLinkRepo {
+	return &ShareLinkRepo{db: db}
+}
+
+func (r *ShareLinkRepo) Create(ctx context.Context, l *models.ShareLink) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Insert("share_links").
+		Columns("id", "document_id", "created_by", "expires_at", "password_hash", "max_downloads").
+		Values(l.ID, l.DocumentID, l.CreatedBy, l.ExpiresAt, nullable(l.PasswordHash), l.MaxDownloads).
+		Suffix("RETURNING created_at").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	return r.db.QueryRow(ctx, sqlStr, args...).Scan(&l.CreatedAt)
+}
+
+// ListByCreator returns the links login has issued, including expired ones,
+// newest first. Links stay with whoever issued them when the document
+// changes hands.
+func (r *ShareLinkRepo) ListByCreator(ctx context.Context, login string) ([]models.ShareLink, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Select(shareLinkColumns...).
+		From("share_links l").
+		Join("documents d ON d.id = l.document_id").
+		Where(sq.Eq{"l.created_by": login}).
+		OrderBy("l.created_at DESC").
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []models.ShareLink
+	for rows.Next() {
+		var l models.ShareLink
+		if err := scanShareLink(rows, &l); err != nil {
+			return nil, err
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+// GetByID returns nil if there is no such link.
+func (r *ShareLinkRepo) GetByID(ctx context.Context, id string) (*models.ShareLink, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Select(shareLinkColumns...).
+		From("share_links l").
+		Join("documents d ON d.id = l.document_id").
+		Where(sq.Eq{"l.id": id}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	var l models.ShareLink
+	if err := scanShareLink(r.db.QueryRow(ctx, sqlStr, args...), &l); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Consume counts a download. It reports false, counting nothing, if the link
+// has expired or used up its downloads in the meantime.
+func (r *ShareLinkRepo) Consume(ctx context.Context, id string) (bool, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Update("share_links").
+		Set("downloads", sq.Expr("downloads + 1")).
+		Where(sq.Eq{"id": id}).
+		Where("expires_at > NOW()").
+		Where("(max_downloads IS NULL OR downloads < max_downloads)").
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() == 1, nil
+}
+
+// Delete revokes a link. Only links to documents owned by owner are matched.
+// Delete removes a link issued by creator.
+func (r *ShareLinkRepo) Delete(ctx context.Context, id, creator string) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Delete("share_links").
+		Where(sq.Eq{"id": id, "created_by": creator}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanShareLink(row pgx.Row, l *models.ShareLink) error {
+	return row.Scan(
+		&l.ID, &l.DocumentID, &l.DocumentName, &l.CreatedBy, &l.ExpiresAt,
+		&l.PasswordHash, &l.MaxDownloads, &l.Downloads, &l.CreatedAt,
+	)
+}
\ No newline at end of file
@@ -11,6 +11,8 @@ import (
 	"docs_storage/internal/models"
 )
 
+var userColumns = []string{"id", "login", "password_hash", "role", "disabled", "created_at", "external_groups", localGroupsColumn("users"), "auth_source"}
+
 type UserRepo struct {
 	db *pgxpool.Pool
 }
@@ -24,8 +26,8 @@ func (r *UserRepo) Create(ctx context.Context, u *models.User) error {
 
 	q := builder.
 		Insert("users").
-		Columns("login", "password_hash").
-		Values(u.Login, u.Password)
+		Columns("login", "password_hash", "role", "auth_source").
+		Values(u.Login, u.Password, u.Role, authSource(u))
 
 	sqlStr, args, err := q.ToSql()
 	if err != nil {
@@ -37,12 +39,119 @@ func (r *UserRepo) Create(ctx context.Context, u *models.User) error {
 }
 
 func (r *UserRepo) GetByLogin(ctx context.Context, login string) (*models.User, error) {
+	return r.getBy(ctx, sq.Eq{"login": login})
+}
+
+func (r *UserRepo) GetByID(ctx context.Context, id int) (*models.User, error) {
+	return r.getBy(ctx, sq.Eq{"id": id})
+}
+
+func (r *UserRepo) List(ctx context.Context, limit, offset int) ([]models.User, int, error) {
 	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
 
+	var total int
+	if err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM users").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
 	q := builder.
-		Select("id", "login", "password_hash", "created_at").
+		Select(userColumns...).
 		From("users").
-		Where(sq.Eq{"login": login}).
+		OrderBy("id")
+	if limit > 0 {
+		q = q.Limit(uint64(limit))
+	}
+	if offset > 0 {
+		q = q.Offset(uint64(offset))
+	}
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var u models.User
+		if err := scanUser(rows, &u); err != nil {
+			return nil, 0, err
+		}
+		users = append(users, u)
+	}
+
+	return users, total, rows.Err()
+}
+
+func (r *UserRepo) SetDisabled(ctx context.Context, id int, disabled bool) error {
+	return r.update(ctx, id, map[string]any{"disabled": disabled})
+}
+
+func (r *UserRepo) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	return r.update(ctx, id, map[string]any{"password_hash": passwordHash})
+}
+
+func (r *UserRepo) SetExternalGroups(ctx context.Context, id int, groups []string) error {
+	if groups == nil {
+		groups = []string{}
+	}
+	return r.update(ctx, id, map[string]any{"external_groups": groups})
+}
+
+// Delete removes the user along with the document grants made to its login,
+// which would otherwise pass to whoever registers the login next.
+func (r *UserRepo) Delete(ctx context.Context, id int) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	sqlStr, args, err := builder.
+		Delete("users").
+		Where(sq.Eq{"id": id}).
+		Suffix("RETURNING login").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	var login string
+	if err := tx.QueryRow(ctx, sqlStr, args...).Scan(&login); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+
+	sqlStr, args, err = builder.
+		Delete("document_acl").
+		Where(sq.Eq{"grantee": login}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, sqlStr, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *UserRepo) getBy(ctx context.Context, cond sq.Sqlizer) (*models.User, error) {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q := builder.
+		Select(userColumns...).
+		From("users").
+		Where(cond).
 		Limit(1)
 
 	sqlStr, args, err := q.ToSql()
@@ -52,7 +161,7 @@ func (r *UserRepo) GetByLogin(ctx context.Context, login string) (*models.User,
 
 	row := r.db.QueryRow(ctx, sqlStr, args...)
 	var u models.User
-	if err := row.Scan(&u.ID, &u.Login, &u.Password, &u.CreatedAt); err != nil {
+	if err := scanUser(row, &u); err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
 		}
@@ -60,3 +169,37 @@ func (r *UserRepo) GetByLogin(ctx context.Context, login string) (*models.User,
 	}
 	return &u, nil
 }
+
+func (r *UserRepo) update(ctx context.Context, id int, fields map[string]any) error {
+	builder := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+
+	q := builder.
+		Update("users").
+		SetMap(fields).
+		Where(sq.Eq{"id": id})
+
+	sqlStr, args, err := q.ToSql()
+	if err != nil {
+		return err
+	}
+
+	cmd, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func scanUser(row pgx.Row, u *models.User) error {
+	return row.Scan(&u.ID, &u.Login, &u.Password, &u.Role, &u.Disabled, &u.CreatedAt, &u.ExternalGroups, &u.Groups, &u.AuthSource)
+}
+
+func authSource(u *models.User) string {
+	if u.AuthSource == "" {
+		return models.AuthSourceLocal
+	}
+	return u.AuthSource
+}
\ No newline at end of file
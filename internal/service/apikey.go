@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	models "docs_storage/internal/models"
+	repository "docs_storage/internal/repository"
+)
+
+var (
+	ErrAPIKeyExpired    = repository.ErrAPIKeyExpired
+	ErrInvalidScope     = errors.New("invalid scope")
+	ErrInvalidKeyName   = errors.New("key name required")
+	ErrInvalidKeyExpiry = errors.New("expiry must be in the future")
+)
+
+type apiKeyRepository interface {
+	Create(ctx context.Context, k *models.APIKey, key string) error
+	ListByUser(ctx context.Context, userID int) ([]models.APIKey, error)
+	Delete(ctx context.Context, userID, id int) error
+}
+
+type APIKeyService struct {
+	keys     apiKeyRepository
+	verifier tokenVerifier
+}
+
+func NewAPIKeyService(keys apiKeyRepository, verifier tokenVerifier) *APIKeyService {
+	return &APIKeyService{keys: keys, verifier: verifier}
+}
+
+// Create issues a key for the caller and returns it together with the plain
+// key, which is not stored and cannot be shown again.
+func (s *APIKeyService) Create(ctx context.Context, token, name string, scopes []string, expiresAt *time.Time) (*models.APIKey, string, error) {
+	session, err := s.interactiveSession(ctx, token)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", ErrInvalidKeyName
+	}
+	if len(scopes) == 0 {
+		return nil, "", ErrInvalidScope
+	}
+	for _, scope := range scopes {
+		if !models.ValidScope(scope) {
+			return nil, "", ErrInvalidScope
+		}
+	}
+	if expiresAt != nil {
+		if !expiresAt.After(time.Now()) {
+			return nil, "", ErrInvalidKeyExpiry
+		}
+		utc := expiresAt.UTC()
+		expiresAt = &utc
+	}
+
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", err
+	}
+	key := models.APIKeyPrefix + hex.EncodeToString(buf)
+
+	k := &models.APIKey{
+		UserID:    session.UserID,
+		Name:      name,
+		Prefix:    key[:len(models.APIKeyPrefix)+8],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.keys.Create(ctx, k, key); err != nil {
+		return nil, "", err
+	}
+	return k, key, nil
+}
+
+func (s *APIKeyService) List(ctx context.Context, token string) ([]models.APIKey, error) {
+	session, err := s.interactiveSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return s.keys.ListByUser(ctx, session.UserID)
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, token string, id int) error {
+	session, err := s.interactiveSession(ctx, token)
+	if err != nil {
+		return err
+	}
+	if err := s.keys.Delete(ctx, session.UserID, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// interactiveSession refuses API keys, so that a leaked key cannot be used to
+// mint further keys or to hide its own revocation.
+func (s *APIKeyService) interactiveSession(ctx context.Context, token string) (*models.Session, error) {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if session.IsAPIKey() {
+		return nil, ErrAccessDenied
+	}
+	return session, nil
+}
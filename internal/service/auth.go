@@ -4,8 +4,12 @@ import (
 	"context"
 	"crypto/rand"
 	"docs_storage/internal/models"
+	"docs_storage/internal/repository"
 	"encoding/hex"
 	"errors"
+	"slices"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -13,29 +17,88 @@ import (
 type userRepository interface {
     Create(ctx context.Context, u *models.User) error
     GetByLogin(ctx context.Context, login string) (*models.User, error)
+    GetByID(ctx context.Context, id int) (*models.User, error)
+    SetExternalGroups(ctx context.Context, id int, groups []string) error
 }
 
 type sessionRepository interface {
     Create(ctx context.Context, s *models.Session) error
     GetByToken(ctx context.Context, token string) (*models.Session, error)
+    Rotate(ctx context.Context, oldToken string, s *models.Session) error
     Delete(ctx context.Context, token string) error
+    DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type mfaChallengeRepository interface {
+    totpRepository
+    CreateChallenge(ctx context.Context, token string, userID int) error
+    AttemptChallenge(ctx context.Context, token string) (userID, attempts int, err error)
+    DeleteChallenge(ctx context.Context, token string) error
+    DeleteExpiredChallenges(ctx context.Context) (int64, error)
+}
+
+type apiKeyLookup interface {
+    GetByKey(ctx context.Context, key string) (*models.Session, error)
+}
+
+var (
+    ErrRefreshUnsupported = errors.New("refresh tokens are only issued in jwt auth mode")
+    ErrAccountDisabled    = errors.New("account disabled")
+)
+
+// AuthTokens is what a successful login or refresh hands back. In session
+// mode Token is an opaque session token and RefreshToken is empty; in JWT
+// mode Token is a signed access token valid for ExpiresIn and RefreshToken
+// is the session that can be exchanged for a new pair.
+//
+// If the user has a second factor, a login instead returns only MFAToken, to be
+// passed to CompleteMFA together with a code within ExpiresIn.
+type AuthTokens struct {
+    Token        string
+    RefreshToken string
+    MFAToken     string
+    ExpiresIn    time.Duration
 }
 
 type AuthService struct {
-    users    userRepository
-    sessions sessionRepository
-    adminTok string
+    users       userRepository
+    credentials credentialVerifier
+    sessions    sessionRepository
+    mfa         mfaChallengeRepository
+    apiKeys     apiKeyLookup
+    jwt         *JWTManager
+    limiter     *LoginLimiter
+    adminTok    string
 }
 
-func NewAuthService(users userRepository, sessions sessionRepository, adminToken string) *AuthService {
-    return &AuthService{users: users, sessions: sessions, adminTok: adminToken}
+// NewAuthService creates the service in session mode when jwt is nil and in
+// JWT mode otherwise. Passwords given to Auth are checked by credentials,
+// and failed attempts are throttled by limiter.
+func NewAuthService(users userRepository, credentials credentialVerifier, sessions sessionRepository, mfa mfaChallengeRepository, apiKeys apiKeyLookup, jwt *JWTManager, limiter *LoginLimiter, adminToken string) *AuthService {
+    return &AuthService{
+        users:       users,
+        credentials: credentials,
+        sessions:    sessions,
+        mfa:         mfa,
+        apiKeys:     apiKeys,
+        jwt:         jwt,
+        limiter:     limiter,
+        adminTok:    adminToken,
+    }
 }
 
-func (s *AuthService) Register(ctx context.Context, token, login, pswd string) error {
+func (s *AuthService) Register(ctx context.Context, token, login, pswd, role string) error {
     if token != s.adminTok {
         return errors.New("unauthorized")
     }
 
+    if role == "" {
+        role = models.RoleEditor
+    }
+    if !models.ValidRole(role) {
+        return errors.New("invalid role")
+    }
+
     hash, err := bcrypt.GenerateFromPassword([]byte(pswd), bcrypt.DefaultCost)
     if err != nil {
         return err
@@ -44,41 +107,267 @@ func (s *AuthService) Register(ctx context.Context, token, login, pswd string) e
     u := &models.User{
         Login:    login,
         Password: string(hash),
+        Role:     role,
     }
     return s.users.Create(ctx, u)
 }
 
-func (s *AuthService) Auth(ctx context.Context, login, pswd string) (string, error) {
-    u, err := s.users.GetByLogin(ctx, login)
+// Auth checks a password login coming from the client address ip. Unknown
+// logins and wrong passwords both yield ErrInvalidCredentials, and repeated
+// failures make further attempts fail with ErrTooManyAttempts for a while,
+// whether or not the password is right.
+func (s *AuthService) Auth(ctx context.Context, login, pswd, ip string) (*AuthTokens, error) {
+    if err := s.limiter.Reserve(ctx, login, ip); err != nil {
+        return nil, err
+    }
+
+    // The attempt already counts as failed; only a verdict other than a
+    // wrong password gives it back.
+    id, err := s.credentials.VerifyCredentials(ctx, login, pswd)
     if err != nil {
-        return "", err
+        if !errors.Is(err, ErrInvalidCredentials) {
+            s.limiter.Release(ctx, login, ip)
+        }
+        return nil, err
+    }
+    s.limiter.Success(ctx, login, ip)
+
+    var u *models.User
+    if id.External {
+        u, err = provisionUser(ctx, s.users, id.Login, id.Role, id.Source)
+    } else {
+        u, err = s.users.GetByLogin(ctx, id.Login)
+    }
+    if err != nil {
+        return nil, err
     }
     if u == nil {
-        return "", errors.New("user not found")
+        return nil, ErrInvalidCredentials
     }
 
-    if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(pswd)); err != nil {
-        return "", errors.New("invalid credentials")
+    if id.External && !slices.Equal(u.ExternalGroups, id.Groups) {
+        if err := s.users.SetExternalGroups(ctx, u.ID, id.Groups); err != nil {
+            return nil, err
+        }
+        u.ExternalGroups = id.Groups
     }
 
-    buf := make([]byte, 16)
-    rand.Read(buf)
-    token := hex.EncodeToString(buf)
+    return s.FinishLogin(ctx, u)
+}
+
+// FinishLogin logs u in once its first factor has been checked, by whichever
+// means: a password, a directory or an identity provider. If u has a second
+// factor, it returns an MFA challenge instead of a session.
+func (s *AuthService) FinishLogin(ctx context.Context, u *models.User) (*AuthTokens, error) {
+    if u.Disabled {
+        return nil, ErrAccountDisabled
+    }
+    t, err := s.mfa.GetTOTP(ctx, u.ID)
+    if err != nil {
+        return nil, err
+    }
+    if t != nil && t.Enabled {
+        return s.challenge(ctx, u)
+    }
+
+    return s.startSession(ctx, u)
+}
+
+// challenge defers the login until the second factor is supplied. No session
+// exists until then.
+func (s *AuthService) challenge(ctx context.Context, u *models.User) (*AuthTokens, error) {
+    token, err := newSessionToken()
+    if err != nil {
+        return nil, err
+    }
+    if err := s.mfa.CreateChallenge(ctx, token, u.ID); err != nil {
+        return nil, err
+    }
+    return &AuthTokens{MFAToken: token, ExpiresIn: MFAChallengeTTL}, nil
+}
+
+// CompleteMFA finishes a login that FinishLogin deferred, given a TOTP or
+// recovery code. A challenge allows only a few wrong codes before it is
+// dropped.
+func (s *AuthService) CompleteMFA(ctx context.Context, mfaToken, code string) (*AuthTokens, error) {
+    userID, attempts, err := s.mfa.AttemptChallenge(ctx, mfaToken)
+    if err != nil {
+        return nil, err
+    }
+    if userID == 0 {
+        return nil, ErrMFAChallenge
+    }
+    if attempts > maxMFAAttempts {
+        if err := s.mfa.DeleteChallenge(ctx, mfaToken); err != nil {
+            return nil, err
+        }
+        return nil, ErrMFAChallenge
+    }
+
+    t, err := s.mfa.GetTOTP(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if t == nil || !t.Enabled {
+        return nil, ErrMFAChallenge
+    }
+    if err := checkSecondFactor(ctx, s.mfa, t, code); err != nil {
+        return nil, err
+    }
+
+    if err := s.mfa.DeleteChallenge(ctx, mfaToken); err != nil {
+        return nil, err
+    }
+
+    u, err := s.users.GetByID(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if u == nil {
+        return nil, ErrMFAChallenge
+    }
+    return s.startSession(ctx, u)
+}
+
+// startSession logs u in once all of its factors have been checked.
+func (s *AuthService) startSession(ctx context.Context, u *models.User) (*AuthTokens, error) {
+    if u.Disabled {
+        return nil, ErrAccountDisabled
+    }
+
+    token, err := newSessionToken()
+    if err != nil {
+        return nil, err
+    }
 
     sess := &models.Session{
-        Token:  token,
-        UserID: u.ID,
-        Login:  u.Login,
+        Token:           token,
+        UserID:          u.ID,
+        Login:           u.Login,
+        Role:            u.Role,
+        Groups:          u.Groups,
+        DirectoryGroups: u.ExternalGroups,
     }
 
     if err := s.sessions.Create(ctx, sess); err != nil {
-        return "", err
+        return nil, err
     }
 
-    return token, nil
+    return s.issue(sess)
 }
 
+// Refresh exchanges a refresh token for a new access token. The refresh
+// token itself is rotated, so each one can be used only once.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*AuthTokens, error) {
+    if s.jwt == nil {
+        return nil, ErrRefreshUnsupported
+    }
+
+    sess, err := s.sessions.GetByToken(ctx, refreshToken)
+    if err != nil {
+        return nil, err
+    }
+    if sess == nil {
+        return nil, ErrInvalidToken
+    }
+
+    token, err := newSessionToken()
+    if err != nil {
+        return nil, err
+    }
 
+    next := &models.Session{
+        Token:           token,
+        UserID:          sess.UserID,
+        Login:           sess.Login,
+        Role:            sess.Role,
+        Groups:          sess.Groups,
+        DirectoryGroups: sess.DirectoryGroups,
+    }
+    if err := s.sessions.Rotate(ctx, refreshToken, next); err != nil {
+        if errors.Is(err, repository.ErrNotFound) {
+            return nil, ErrInvalidToken
+        }
+        return nil, err
+    }
+
+    return s.issue(next)
+}
+
+// Verify resolves a bearer token to the session it stands for. API keys are
+// recognised by their prefix and accepted in either auth mode; other tokens
+// are checked as JWTs or looked up as sessions depending on the mode.
+func (s *AuthService) Verify(ctx context.Context, token string) (*models.Session, error) {
+    if token == "" {
+        return nil, ErrInvalidToken
+    }
+
+    var sess *models.Session
+    var err error
+    switch {
+    case strings.HasPrefix(token, models.APIKeyPrefix):
+        sess, err = s.apiKeys.GetByKey(ctx, token)
+    case s.jwt != nil:
+        return s.jwt.Verify(ctx, token)
+    default:
+        sess, err = s.sessions.GetByToken(ctx, token)
+    }
+    if err != nil {
+        return nil, err
+    }
+    if sess == nil {
+        return nil, ErrInvalidToken
+    }
+    return sess, nil
+}
+
+func (s *AuthService) issue(sess *models.Session) (*AuthTokens, error) {
+    if s.jwt == nil {
+        return &AuthTokens{Token: sess.Token}, nil
+    }
+
+    access, err := s.jwt.Issue(sess)
+    if err != nil {
+        return nil, err
+    }
+    return &AuthTokens{Token: access, RefreshToken: sess.Token, ExpiresIn: s.jwt.TTL()}, nil
+}
+
+// Logout drops a session. In JWT mode the token is the refresh token; access
+// tokens already issued for it run out on their own.
 func (s *AuthService) Logout(ctx context.Context, token string) error {
     return s.sessions.Delete(ctx, token)
 }
+
+// RunSessionCleanup periodically purges expired sessions and MFA challenges
+// until ctx is cancelled.
+func (s *AuthService) RunSessionCleanup(ctx context.Context, interval time.Duration, onError func(error)) {
+    if interval <= 0 {
+        return
+    }
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if _, err := s.sessions.DeleteExpired(ctx); err != nil && onError != nil {
+                onError(err)
+            }
+            if _, err := s.mfa.DeleteExpiredChallenges(ctx); err != nil && onError != nil {
+                onError(err)
+            }
+        }
+    }
+}
+
+func newSessionToken() (string, error) {
+    buf := make([]byte, 16)
+    if _, err := rand.Read(buf); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(buf), nil
+}
\ No newline at end of file
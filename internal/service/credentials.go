@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+
+	models "docs_storage/internal/models"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountSource      = errors.New("login belongs to an account of another source")
+)
+
+// Identity is a login whose password has been checked.
+type Identity struct {
+	Login string
+	// External is set for accounts that live in an outside directory, named
+	// by Source. They are created on first login with Role, and their Groups
+	// are refreshed on every login.
+	External bool
+	Source   string
+	Role     string
+	Groups   []string
+}
+
+// credentialVerifier checks a login and password. A wrong pair, including an
+// unknown login, yields ErrInvalidCredentials.
+type credentialVerifier interface {
+	VerifyCredentials(ctx context.Context, login, pswd string) (*Identity, error)
+}
+
+type localUserGetter interface {
+	GetByLogin(ctx context.Context, login string) (*models.User, error)
+}
+
+// dummyHash is compared against when the login does not exist.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("docs_storage"), bcrypt.DefaultCost)
+
+// LocalCredentials checks passwords against the bcrypt hashes in users.
+type LocalCredentials struct {
+	users localUserGetter
+}
+
+func NewLocalCredentials(users localUserGetter) *LocalCredentials {
+	return &LocalCredentials{users: users}
+}
+
+func (c *LocalCredentials) VerifyCredentials(ctx context.Context, login, pswd string) (*Identity, error) {
+	u, err := c.users.GetByLogin(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	hash := dummyHash
+	if u != nil {
+		hash = []byte(u.Password)
+	}
+	// Unknown logins still pay for a bcrypt comparison, so response times do
+	// not tell which logins exist.
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(pswd)); err != nil || u == nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{Login: u.Login}, nil
+}
+
+// CredentialChain asks each verifier in turn until one accepts the login.
+// This lets a directory be the primary source while local accounts keep
+// working, e.g. for a break-glass admin.
+type CredentialChain struct {
+	verifiers []credentialVerifier
+}
+
+func NewCredentialChain(verifiers ...credentialVerifier) *CredentialChain {
+	return &CredentialChain{verifiers: verifiers}
+}
+
+func (c *CredentialChain) VerifyCredentials(ctx context.Context, login, pswd string) (*Identity, error) {
+	// A failure that is not a plain rejection, such as the directory being
+	// unreachable, is reported only if no later verifier accepts the login.
+	var failure error
+	for _, v := range c.verifiers {
+		id, err := v.VerifyCredentials(ctx, login, pswd)
+		if err == nil {
+			return id, nil
+		}
+		if failure == nil && !errors.Is(err, ErrInvalidCredentials) {
+			failure = err
+		}
+	}
+	if failure != nil {
+		return nil, failure
+	}
+	return nil, ErrInvalidCredentials
+}
+
+type userProvisioner interface {
+	Create(ctx context.Context, u *models.User) error
+	GetByLogin(ctx context.Context, login string) (*models.User, error)
+}
+
+// provisionUser returns the user with the given login, creating it with the
+// given role if it does not exist yet. Such users have no password and can
+// only log in through the external source that vouched for them. A login
+// taken by an account of another source, such as a local admin, is refused
+// with ErrAccountSource, so that the outside source cannot take it over.
+func provisionUser(ctx context.Context, users userProvisioner, login, role, source string) (*models.User, error) {
+	u, err := users.GetByLogin(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	if u != nil {
+		return ownedBy(u, source)
+	}
+
+	if !models.ValidRole(role) {
+		role = models.RoleEditor
+	}
+	createErr := users.Create(ctx, &models.User{Login: login, Role: role, AuthSource: source})
+
+	// Re-read even if creation failed: a concurrent first login may have
+	// created the user in the meantime.
+	u, err = users.GetByLogin(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		if createErr != nil {
+			return nil, createErr
+		}
+		return nil, ErrNotFound
+	}
+	return ownedBy(u, source)
+}
+
+func ownedBy(u *models.User, source string) (*models.User, error) {
+	if u.AuthSource != source || u.Password != "" {
+		return nil, ErrAccountSource
+	}
+	return u, nil
+}
\ No newline at end of file
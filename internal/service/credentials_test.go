@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	models "docs_storage/internal/models"
+)
+
+// memUsers is an in-memory user table for the credential and provisioning
+// tests.
+type memUsers struct {
+	users []*models.User
+}
+
+func (r *memUsers) GetByLogin(_ context.Context, login string) (*models.User, error) {
+	for _, u := range r.users {
+		if u.Login == login {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memUsers) Create(ctx context.Context, u *models.User) error {
+	if existing, _ := r.GetByLogin(ctx, u.Login); existing != nil {
+		return errors.New("login taken")
+	}
+	u.ID = len(r.users) + 1
+	r.users = append(r.users, u)
+	return nil
+}
+
+func localUser(t *testing.T, login, password string) *models.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &models.User{Login: login, Password: string(hash), Role: models.RoleAdmin, AuthSource: models.AuthSourceLocal}
+}
+
+func TestCredentialChainFallback(t *testing.T) {
+	d := newPeopleDirectory(t)
+	users := &memUsers{users: []*models.User{localUser(t, "admin", "admin-pwd")}}
+	chain := NewCredentialChain(NewLDAPCredentials(testLDAPConfig(d)), NewLocalCredentials(users))
+
+	id, err := chain.VerifyCredentials(context.Background(), "alice", "alice-pwd")
+	if err != nil || !id.External {
+		t.Fatalf("directory user: got %+v, %v", id, err)
+	}
+	id, err = chain.VerifyCredentials(context.Background(), "admin", "admin-pwd")
+	if err != nil || id.External || id.Login != "admin" {
+		t.Fatalf("local user: got %+v, %v", id, err)
+	}
+	if _, err := chain.VerifyCredentials(context.Background(), "admin", "alice-pwd"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("wrong password: got %v, want %v", err, ErrInvalidCredentials)
+	}
+}
+
+func TestCredentialChainFallbackWhileDirectoryDown(t *testing.T) {
+	users := &memUsers{users: []*models.User{localUser(t, "admin", "admin-pwd")}}
+	down := NewLDAPCredentials(LDAPConfig{URL: "ldap://127.0.0.1:1", BaseDN: testBaseDN})
+	chain := NewCredentialChain(down, NewLocalCredentials(users))
+
+	// The break-glass account still gets in.
+	if _, err := chain.VerifyCredentials(context.Background(), "admin", "admin-pwd"); err != nil {
+		t.Fatalf("local user: %v", err)
+	}
+	// A rejection is not reported as such while the directory could not
+	// be asked.
+	_, err := chain.VerifyCredentials(context.Background(), "alice", "alice-pwd")
+	if err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("got %v, want the directory error", err)
+	}
+}
+
+func TestCredentialChainReplace(t *testing.T) {
+	d := newPeopleDirectory(t)
+	chain := NewCredentialChain(NewLDAPCredentials(testLDAPConfig(d)))
+
+	if _, err := chain.VerifyCredentials(context.Background(), "alice", "alice-pwd"); err != nil {
+		t.Fatalf("directory user: %v", err)
+	}
+	// Only the directory is asked, so a login it does not know is refused.
+	if _, err := chain.VerifyCredentials(context.Background(), "admin", "admin-pwd"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("unknown to the directory: got %v, want %v", err, ErrInvalidCredentials)
+	}
+}
+
+func TestProvisionUser(t *testing.T) {
+	ctx := context.Background()
+	users := &memUsers{users: []*models.User{localUser(t, "admin", "admin-pwd")}}
+
+	u, err := provisionUser(ctx, users, "alice", models.RoleViewer, models.AuthSourceLDAP)
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if u.Role != models.RoleViewer || u.AuthSource != models.AuthSourceLDAP || u.Password != "" {
+		t.Fatalf("created user %+v", u)
+	}
+
+	again, err := provisionUser(ctx, users, "alice", models.RoleAdmin, models.AuthSourceLDAP)
+	if err != nil || again.ID != u.ID || again.Role != models.RoleViewer {
+		t.Fatalf("second login: got %+v, %v", again, err)
+	}
+
+	u, err = provisionUser(ctx, users, "bob", "superuser", models.AuthSourceLDAP)
+	if err != nil || u.Role != models.RoleEditor {
+		t.Fatalf("invalid role: got %+v, %v", u, err)
+	}
+
+	tests := []struct {
+		name, login, source string
+	}{
+		{"local account", "admin", models.AuthSourceLDAP},
+		{"account of another source", "alice", models.AuthSourceOIDC},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := provisionUser(ctx, users, tt.login, models.RoleViewer, tt.source); !errors.Is(err, ErrAccountSource) {
+				t.Fatalf("got %v, want %v", err, ErrAccountSource)
+			}
+		})
+	}
+}
\ No newline at end of file
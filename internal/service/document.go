@@ -2,34 +2,63 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	models "docs_storage/internal/models"
+	repository "docs_storage/internal/repository"
 )
 
 var (
 	ErrNotFound     = errors.New("not found")
 	ErrAccessDenied = errors.New("access denied")
+	ErrEmptyUpdate  = errors.New("nothing to update")
+	ErrInvalidGrant = errors.New("invalid grantee or permission")
+
+	ErrInvalidDocumentName = errors.New("document name must not be empty")
+	ErrNameTaken           = errors.New("a document with this name already exists")
+	ErrInvalidListing      = errors.New("invalid listing parameters")
+	ErrEmptySearch         = errors.New("search query required")
+
+	// ErrConflict means another request added a version first; the client
+	// should reload the document and try again.
+	ErrConflict = repository.ErrConflict
 )
 
 type docsRepository interface {
 	Save(ctx context.Context, doc *models.Document) error
-	List(ctx context.Context, requesterLogin, login, key, value string, limit int) ([]models.Document, error)
+	List(ctx context.Context, f models.DocumentFilter) (*models.DocumentPage, error)
+	Search(ctx context.Context, f models.DocumentFilter, text string) ([]models.SearchResult, error)
 	GetByID(ctx context.Context, id string) (*models.Document, error)
 	Delete(ctx context.Context, id string) error
+	AddVersion(ctx context.Context, v *models.DocumentVersion) error
+	ListVersions(ctx context.Context, id string) ([]models.DocumentVersion, error)
+	GetVersion(ctx context.Context, id string, version int) (*models.DocumentVersion, error)
+	ReassignOwner(ctx context.Context, from, to string) error
+	Share(ctx context.Context, id string, e models.ACLEntry, grantedBy string) error
+	SetACLEntry(ctx context.Context, id string, e models.ACLEntry, grantedBy string) error
+	DeleteACLEntry(ctx context.Context, id, grantee string) error
+	UpdateMeta(ctx context.Context, id string, name *string, public *bool) error
 }
 
-type fileStorage interface {
-	Save(fileName string, data []byte) (string, error)
-	Delete(fileName string) error
+type blobRepository interface {
+	Acquire(ctx context.Context, b *models.Blob) error
+	AddRef(ctx context.Context, checksum string) error
+	Release(ctx context.Context, checksum string, onLast func(key string) error) error
 }
 
-type sessionRepo interface {
-	GetByToken(ctx context.Context, token string) (*models.Session, error)
+type fileStorage interface {
+	Stage(ctx context.Context, r io.Reader) (*models.Blob, string, error)
+	Commit(ctx context.Context, tmpKey string, b *models.Blob) error
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	Delete(ctx context.Context, key string) error
+	SweepStaged(ctx context.Context, before time.Time) (int, error)
 }
 
 type cache interface {
@@ -39,31 +68,51 @@ type cache interface {
 	DeletePrefix(ctx context.Context, prefix string)
 }
 
+// extractionQueue hands the files of new document versions to the text
+// extraction in the background.
+type extractionQueue interface {
+	Enqueue(ctx context.Context, id string, version int)
+	Forget(ctx context.Context, id string)
+}
+
 type DocsService struct {
 	docsRepo    docsRepository
+	blobs       blobRepository
 	fileStorage fileStorage
-	sessions    sessionRepo
+	verifier    tokenVerifier
 	cache       cache
+	extractor   extractionQueue
 }
 
-func NewDocsService(docRepo docsRepository, fileStorage fileStorage, sessions sessionRepo, c cache) *DocsService {
+func NewDocsService(docRepo docsRepository, blobs blobRepository, fileStorage fileStorage, verifier tokenVerifier, c cache, extractor extractionQueue) *DocsService {
 	return &DocsService{
 		docsRepo:    docRepo,
+		blobs:       blobs,
 		fileStorage: fileStorage,
-		sessions:    sessions,
+		verifier:    verifier,
 		cache:       c,
+		extractor:   extractor,
 	}
 }
 
-func (s *DocsService) Create(ctx context.Context, meta *models.Document, fileName string, fileData []byte, jsonData []byte, token string) (*models.Document, error) {
-	session, err := s.sessions.GetByToken(ctx, token)
+func (s *DocsService) Create(ctx context.Context, meta *models.Document, file io.Reader, jsonData []byte, token string) (*models.Document, error) {
+	session, err := s.verifier.Verify(ctx, token)
 	if err != nil {
 		return nil, err
 	}
-	if session == nil {
+	if !canUpload(session) {
 		return nil, ErrAccessDenied
 	}
 
+	acl := meta.ACL
+	for _, grantee := range meta.Grant {
+		acl = append(acl, models.ACLEntry{Grantee: grantee})
+	}
+	acl, err = normalizeACL(acl, session.Login)
+	if err != nil {
+		return nil, err
+	}
+
 	doc := &models.Document{
 		ID:         uuid.New().String(),
 		Name:       meta.Name,
@@ -71,67 +120,154 @@ func (s *DocsService) Create(ctx context.Context, meta *models.Document, fileNam
 		File:       meta.File,
 		Public:     meta.Public,
 		OwnerLogin: session.Login,
-		Grant:      meta.Grant,
+		ACL:        acl,
 		CreatedAt:  time.Now(),
 		JSONData:   jsonData,
 	}
 
-	if meta.File && len(fileData) > 0 {
-		path, err := s.fileStorage.Save(fileName, fileData)
+	if meta.File && file != nil {
+		blob, err := s.storeBlob(ctx, file)
 		if err != nil {
 			return nil, err
 		}
-		doc.FilePath = path
+		doc.FilePath = blob.Key
+		doc.Checksum = blob.Checksum
 	}
 
 	if err := s.docsRepo.Save(ctx, doc); err != nil {
+		s.releaseBlob(ctx, doc.Checksum)
 		return nil, err
 	}
 
-	s.cache.DeletePrefix(ctx, fmt.Sprintf("list:%s", session.Login))
+	if doc.FilePath != "" {
+		s.extractor.Enqueue(ctx, doc.ID, doc.Version)
+		doc.ExtractionStatus = models.ExtractionPending
+	}
+
+	invalidateLists(ctx, s.cache, doc)
 	s.cache.Set(ctx, fmt.Sprintf("doc:%s", doc.ID), doc)
 
 	return doc, nil
 }
 
-func (s *DocsService) List(ctx context.Context, token, login, key, value string, limit int) ([]models.Document, error) {
-	session, err := s.sessions.GetByToken(ctx, token)
+func (s *DocsService) List(ctx context.Context, token string, q models.DocumentQuery) (*models.DocumentPage, error) {
+	session, err := s.verifier.Verify(ctx, token)
 	if err != nil {
 		return nil, err
 	}
-	if session == nil {
+	if !session.HasScope(models.ScopeRead) {
 		return nil, ErrAccessDenied
 	}
 
-	cacheKey := fmt.Sprintf("list:%s:%s:%s:%s:%d", session.Login, login, key, value, limit)
+	filter := models.DocumentFilter{
+		RequesterLogin:      session.Login,
+		RequesterPrincipals: session.Principals(),
+		All:                 session.IsAdmin(),
+		DocumentQuery:       q,
+	}
+
+	// An admin's listing depends on every user's documents, which the
+	// per-login invalidation below cannot track, so it is never cached.
+	if filter.All {
+		return s.listPage(ctx, filter)
+	}
+
+	cacheKey := fmt.Sprintf("list:%s:%s:%s", session.Login, strings.Join(filter.RequesterPrincipals, ","), queryCacheKey(q))
 	if cached, ok := s.cache.Get(ctx, cacheKey); ok {
-		if docs, ok := cached.([]models.Document); ok {
-			return docs, nil
+		if page, ok := cached.(*models.DocumentPage); ok {
+			return page, nil
 		}
 	}
 
-	docs, err := s.docsRepo.List(ctx, session.Login, login, key, value, limit)
+	page, err := s.listPage(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	s.cache.Set(ctx, cacheKey, docs)
-	return docs, nil
+	s.cache.Set(ctx, cacheKey, page)
+	return page, nil
 }
 
-func (s *DocsService) GetByID(ctx context.Context, id, token string) (*models.Document, error) {
-	session, err := s.sessions.GetByToken(ctx, token)
+// queryCacheKey identifies a listing's parameters, cursor and all, within
+// the requester's part of the cache.
+func queryCacheKey(q models.DocumentQuery) string {
+	key, _ := json.Marshal(q)
+	return string(key)
+}
+
+func (s *DocsService) listPage(ctx context.Context, f models.DocumentFilter) (*models.DocumentPage, error) {
+	page, err := s.docsRepo.List(ctx, f)
+	if errors.Is(err, repository.ErrInvalidSort) || errors.Is(err, repository.ErrInvalidCursor) ||
+		errors.Is(err, repository.ErrInvalidFilter) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidListing, err)
+	}
+	return page, err
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Search finds the documents the caller can see whose name or JSON data
+// match text, best match first. Filters narrow the results as in List;
+// sorting and cursors do not apply. Results are not cached.
+func (s *DocsService) Search(ctx context.Context, token, text string, q models.DocumentQuery) ([]models.SearchResult, error) {
+	session, err := s.verifier.Verify(ctx, token)
 	if err != nil {
 		return nil, err
 	}
-	if session == nil {
+	if !session.HasScope(models.ScopeRead) {
 		return nil, ErrAccessDenied
 	}
 
+	return s.search(ctx, models.DocumentFilter{
+		RequesterLogin:      session.Login,
+		RequesterPrincipals: session.Principals(),
+		All:                 session.IsAdmin(),
+		DocumentQuery:       q,
+	}, text)
+}
+
+// SearchPublic is Search over public documents, for requests without a
+// session.
+func (s *DocsService) SearchPublic(ctx context.Context, text string, q models.DocumentQuery) ([]models.SearchResult, error) {
+	results, err := s.search(ctx, models.DocumentFilter{PublicOnly: true, DocumentQuery: q}, text)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		results[i].ACL = nil
+	}
+	return results, nil
+}
+
+func (s *DocsService) search(ctx context.Context, f models.DocumentFilter, text string) ([]models.SearchResult, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, ErrEmptySearch
+	}
+	if f.Limit <= 0 {
+		f.Limit = defaultSearchLimit
+	}
+	f.Limit = min(f.Limit, maxSearchLimit)
+
+	results, err := s.docsRepo.Search(ctx, f, text)
+	if errors.Is(err, repository.ErrInvalidFilter) {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidListing, err)
+	}
+	return results, err
+}
+
+func (s *DocsService) GetByID(ctx context.Context, id, token string) (*models.Document, error) {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
 	cacheKey := fmt.Sprintf("doc:%s", id)
 	if cached, ok := s.cache.Get(ctx, cacheKey); ok {
 		if doc, ok := cached.(*models.Document); ok {
-			if doc.Public || doc.OwnerLogin == session.Login || slices.Contains(doc.Grant, session.Login) {
+			if authorize(session, doc, models.PermRead) {
 				return doc, nil
 			}
 			return nil, ErrAccessDenied
@@ -146,7 +282,7 @@ func (s *DocsService) GetByID(ctx context.Context, id, token string) (*models.Do
 		return nil, ErrNotFound
 	}
 
-	if doc.Public || doc.OwnerLogin == session.Login || slices.Contains(doc.Grant, session.Login) {
+	if authorize(session, doc, models.PermRead) {
 		s.cache.Set(ctx, cacheKey, doc)
 		return doc, nil
 	}
@@ -154,14 +290,404 @@ func (s *DocsService) GetByID(ctx context.Context, id, token string) (*models.Do
 	return nil, ErrAccessDenied
 }
 
+// publicCachePrefix keeps what anonymous requests are served apart from the
+// per-requester entries, so that the two can never be mixed up.
+const publicCachePrefix = "public:"
+
+// ListPublic lists public documents for requests without a session.
+func (s *DocsService) ListPublic(ctx context.Context, q models.DocumentQuery) (*models.DocumentPage, error) {
+	cacheKey := fmt.Sprintf("%slist:%s", publicCachePrefix, queryCacheKey(q))
+	if cached, ok := s.cache.Get(ctx, cacheKey); ok {
+		if page, ok := cached.(*models.DocumentPage); ok {
+			return page, nil
+		}
+	}
+
+	page, err := s.listPage(ctx, models.DocumentFilter{PublicOnly: true, DocumentQuery: q})
+	if err != nil {
+		return nil, err
+	}
+	for i := range page.Docs {
+		page.Docs[i].ACL = nil
+	}
+
+	s.cache.Set(ctx, cacheKey, page)
+	return page, nil
+}
+
+// GetPublic returns a public document for a request without a session.
+// Documents that are not public are reported as not found, so that their
+// existence is not given away.
+func (s *DocsService) GetPublic(ctx context.Context, id string) (*models.Document, error) {
+	cacheKey := fmt.Sprintf("%sdoc:%s", publicCachePrefix, id)
+	if cached, ok := s.cache.Get(ctx, cacheKey); ok {
+		if doc, ok := cached.(*models.Document); ok {
+			return doc, nil
+		}
+	}
+
+	doc, err := s.docsRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil || !doc.Public {
+		return nil, ErrNotFound
+	}
+	doc.ACL = nil
+
+	s.cache.Set(ctx, cacheKey, doc)
+	return doc, nil
+}
+
+func (s *DocsService) Update(ctx context.Context, id string, meta *models.Document, file io.Reader, jsonData []byte, token string) (*models.Document, error) {
+	if file == nil && jsonData == nil {
+		return nil, ErrEmptyUpdate
+	}
+
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := s.docsRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, ErrNotFound
+	}
+
+	if !authorize(session, doc, models.PermWrite) {
+		return nil, ErrAccessDenied
+	}
+
+	v := &models.DocumentVersion{
+		DocumentID: doc.ID,
+		Version:    doc.Version + 1,
+		Mime:       doc.Mime,
+		File:       doc.File,
+		JSONData:   doc.JSONData,
+		FilePath:   doc.FilePath,
+		Checksum:   doc.Checksum,
+		CreatedBy:  session.Login,
+		CreatedAt:  time.Now(),
+	}
+	if meta != nil && meta.Mime != "" {
+		v.Mime = meta.Mime
+	}
+	if jsonData != nil {
+		v.JSONData = jsonData
+	}
+	if file != nil {
+		blob, err := s.storeBlob(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		v.File = true
+		v.FilePath = blob.Key
+		v.Checksum = blob.Checksum
+	} else if err := s.addBlobRef(ctx, v.Checksum); err != nil {
+		return nil, err
+	}
+
+	return s.addVersion(ctx, doc, v)
+}
+
+func (s *DocsService) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if key == "" {
+		return nil, ErrNotFound
+	}
+	return s.fileStorage.Open(ctx, key)
+}
+
+func (s *DocsService) ListVersions(ctx context.Context, id, token string) ([]models.DocumentVersion, error) {
+	if _, err := s.GetByID(ctx, id, token); err != nil {
+		return nil, err
+	}
+
+	return s.docsRepo.ListVersions(ctx, id)
+}
+
+func (s *DocsService) GetVersion(ctx context.Context, id string, version int, token string) (*models.DocumentVersion, error) {
+	if _, err := s.GetByID(ctx, id, token); err != nil {
+		return nil, err
+	}
+
+	cacheKey := fmt.Sprintf("doc:%s:v%d", id, version)
+	if cached, ok := s.cache.Get(ctx, cacheKey); ok {
+		if v, ok := cached.(*models.DocumentVersion); ok {
+			return v, nil
+		}
+	}
+
+	v, err := s.docsRepo.GetVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return nil, ErrNotFound
+	}
+
+	s.cache.Set(ctx, cacheKey, v)
+	return v, nil
+}
+
+func (s *DocsService) Restore(ctx context.Context, id string, version int, token string) (*models.Document, error) {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := s.docsRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, ErrNotFound
+	}
+
+	if !authorize(session, doc, models.PermWrite) {
+		return nil, ErrAccessDenied
+	}
+
+	old, err := s.docsRepo.GetVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	if old == nil {
+		return nil, ErrNotFound
+	}
+
+	v := &models.DocumentVersion{
+		DocumentID: doc.ID,
+		Version:    doc.Version + 1,
+		Mime:       old.Mime,
+		File:       old.File,
+		JSONData:   old.JSONData,
+		FilePath:   old.FilePath,
+		Checksum:   old.Checksum,
+		CreatedBy:  session.Login,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.addBlobRef(ctx, v.Checksum); err != nil {
+		return nil, err
+	}
+
+	return s.addVersion(ctx, doc, v)
+}
+
+func (s *DocsService) addVersion(ctx context.Context, doc *models.Document, v *models.DocumentVersion) (*models.Document, error) {
+	if err := s.docsRepo.AddVersion(ctx, v); err != nil {
+		s.releaseBlob(ctx, v.Checksum)
+		return nil, err
+	}
+
+	updated := *doc
+	updated.Version = v.Version
+	updated.Mime = v.Mime
+	updated.File = v.File
+	updated.JSONData = v.JSONData
+	updated.FilePath = v.FilePath
+	updated.Checksum = v.Checksum
+
+	if updated.FilePath != "" {
+		s.extractor.Enqueue(ctx, doc.ID, v.Version)
+		updated.ExtractionStatus = models.ExtractionPending
+	} else {
+		s.extractor.Forget(ctx, doc.ID)
+		updated.ExtractionStatus = ""
+	}
+
+	invalidateLists(ctx, s.cache, &updated)
+	s.cache.Set(ctx, fmt.Sprintf("doc:%s", doc.ID), &updated)
+
+	return &updated, nil
+}
+
+// Share grants perms on the document to grantee, on top of whatever the
+// grantee has already. It takes the share permission, and the caller can
+// only hand out permissions they hold themselves.
+func (s *DocsService) Share(ctx context.Context, id, token, grantee string, perms []string) (*models.Document, error) {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := s.docsRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, ErrNotFound
+	}
+	if !authorize(session, doc, models.PermShare) {
+		return nil, ErrAccessDenied
+	}
+
+	acl, err := normalizeACL([]models.ACLEntry{{Grantee: grantee, Permissions: perms}}, doc.OwnerLogin)
+	if err != nil {
+		return nil, err
+	}
+	if len(acl) == 0 {
+		return doc, nil
+	}
+	entry := acl[0]
+	for _, p := range entry.Permissions {
+		if !authorize(session, doc, p) {
+			return nil, ErrAccessDenied
+		}
+	}
+
+	if err := s.docsRepo.Share(ctx, doc.ID, entry, session.Login); err != nil {
+		return nil, err
+	}
+	return s.reload(ctx, doc)
+}
+
+// ListGrants returns the document's ACL. Like the other grant management
+// operations it is open to the owner only.
+func (s *DocsService) ListGrants(ctx context.Context, id, token string) ([]models.ACLEntry, error) {
+	_, doc, err := s.getForOwner(ctx, id, token)
+	if err != nil {
+		return nil, err
+	}
+	return doc.ACL, nil
+}
+
+// SetGrant gives grantee exactly perms on the document, replacing what it
+// had before.
+func (s *DocsService) SetGrant(ctx context.Context, id, token, grantee string, perms []string) (*models.Document, error) {
+	session, doc, err := s.getForOwner(ctx, id, token)
+	if err != nil {
+		return nil, err
+	}
+
+	acl, err := normalizeACL([]models.ACLEntry{{Grantee: grantee, Permissions: perms}}, doc.OwnerLogin)
+	if err != nil {
+		return nil, err
+	}
+	if len(acl) == 0 {
+		return nil, ErrInvalidGrant
+	}
+
+	if err := s.docsRepo.SetACLEntry(ctx, doc.ID, acl[0], session.Login); err != nil {
+		return nil, err
+	}
+	return s.reload(ctx, doc)
+}
+
+func (s *DocsService) RevokeGrant(ctx context.Context, id, token, grantee string) (*models.Document, error) {
+	_, doc, err := s.getForOwner(ctx, id, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.docsRepo.DeleteACLEntry(ctx, doc.ID, grantee); err != nil {
+		return nil, notFound(err)
+	}
+	return s.reload(ctx, doc)
+}
+
+// UpdateMeta renames the document and makes it public or private. Fields
+// left nil are not changed.
+func (s *DocsService) UpdateMeta(ctx context.Context, id, token string, name *string, public *bool) (*models.Document, error) {
+	if name == nil && public == nil {
+		return nil, ErrEmptyUpdate
+	}
+
+	_, doc, err := s.getForOwner(ctx, id, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != nil {
+		trimmed := strings.TrimSpace(*name)
+		if trimmed == "" {
+			return nil, ErrInvalidDocumentName
+		}
+		name = &trimmed
+	}
+
+	if err := s.docsRepo.UpdateMeta(ctx, doc.ID, name, public); err != nil {
+		if errors.Is(err, repository.ErrNameTaken) {
+			return nil, ErrNameTaken
+		}
+		return nil, notFound(err)
+	}
+	return s.reload(ctx, doc)
+}
+
+// getForOwner loads a document for one of the operations reserved to its
+// owner.
+func (s *DocsService) getForOwner(ctx context.Context, id, token string) (*models.Session, *models.Document, error) {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := s.docsRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if doc == nil {
+		return nil, nil, ErrNotFound
+	}
+	if !authorize(session, doc, permManage) {
+		return nil, nil, ErrAccessDenied
+	}
+	return session, doc, nil
+}
+
+// reload reads the document back after its metadata or ACL changed and
+// refreshes the cache for everyone who could see it before or can see it
+// now.
+func (s *DocsService) reload(ctx context.Context, before *models.Document) (*models.Document, error) {
+	doc, err := s.docsRepo.GetByID(ctx, before.ID)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, ErrNotFound
+	}
+
+	s.cache.Set(ctx, fmt.Sprintf("doc:%s", doc.ID), doc)
+	invalidateLists(ctx, s.cache, before, doc)
+	return doc, nil
+}
+
+// invalidateLists drops the cached listings that may include any of docs.
+// Listings are cached per requester, so normally only the owners' and the
+// grantees' are affected. Who sees a public document or one granted to a
+// group is not known here, so in that case all listings go, along with
+// everything cached for anonymous requests.
+func invalidateLists(ctx context.Context, c cache, docs ...*models.Document) {
+	logins := make(map[string]bool)
+	for _, d := range docs {
+		if d.Public {
+			c.DeletePrefix(ctx, "list:")
+			c.DeletePrefix(ctx, publicCachePrefix)
+			return
+		}
+		logins[d.OwnerLogin] = true
+		for _, grantee := range d.Grantees() {
+			if strings.HasPrefix(grantee, models.GroupPrincipal("")) || strings.HasPrefix(grantee, models.DirectoryPrincipal("")) {
+				c.DeletePrefix(ctx, "list:")
+				return
+			}
+			logins[grantee] = true
+		}
+	}
+
+	for login := range logins {
+		c.DeletePrefix(ctx, fmt.Sprintf("list:%s:", login))
+	}
+}
+
 func (s *DocsService) Delete(ctx context.Context, id, token string) error {
-	session, err := s.sessions.GetByToken(ctx, token)
+	session, err := s.verifier.Verify(ctx, token)
 	if err != nil {
 		return err
 	}
-	if session == nil {
-		return ErrAccessDenied
-	}
 
 	doc, err := s.docsRepo.GetByID(ctx, id)
 	if err != nil {
@@ -171,20 +697,221 @@ func (s *DocsService) Delete(ctx context.Context, id, token string) error {
 		return ErrNotFound
 	}
 
-	if doc.OwnerLogin != session.Login {
+	if !authorize(session, doc, models.PermDelete) {
 		return ErrAccessDenied
 	}
 
-	if err := s.docsRepo.Delete(ctx, id); err != nil {
+	return s.deleteDocument(ctx, doc)
+}
+
+// DeleteOwnedBy removes every document owned by login. It is meant for
+// account removal and performs no access checks of its own.
+func (s *DocsService) DeleteOwnedBy(ctx context.Context, login string) error {
+	page, err := s.docsRepo.List(ctx, models.DocumentFilter{All: true, DocumentQuery: models.DocumentQuery{Login: login}})
+	if err != nil {
+		return err
+	}
+
+	for i := range page.Docs {
+		if err := s.deleteDocument(ctx, &page.Docs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReassignOwner hands every document owned by from over to to. Like
+// DeleteOwnedBy it performs no access checks of its own.
+func (s *DocsService) ReassignOwner(ctx context.Context, from, to string) error {
+	if err := s.docsRepo.ReassignOwner(ctx, from, to); err != nil {
+		return err
+	}
+
+	// Grantees, group members and anonymous readers may have the documents
+	// cached too, and which ones is not known here.
+	s.cache.DeletePrefix(ctx, "doc:")
+	s.cache.DeletePrefix(ctx, "list:")
+	s.cache.DeletePrefix(ctx, publicCachePrefix)
+	return nil
+}
+
+// ForgetGrantee drops what is cached for documents after the grants made to
+// a deleted login are gone. Which documents named it is not known here.
+func (s *DocsService) ForgetGrantee(ctx context.Context, login string) {
+	s.cache.DeletePrefix(ctx, "doc:")
+	s.cache.DeletePrefix(ctx, fmt.Sprintf("list:%s:", login))
+}
+
+func (s *DocsService) deleteDocument(ctx context.Context, doc *models.Document) error {
+	versions, err := s.docsRepo.ListVersions(ctx, doc.ID)
+	if err != nil {
 		return err
 	}
 
-	if doc.File && doc.FilePath != "" {
-		_ = s.fileStorage.Delete(doc.FilePath)
+	if err := s.docsRepo.Delete(ctx, doc.ID); err != nil {
+		return err
 	}
 
-	s.cache.Delete(ctx, fmt.Sprintf("doc:%s", id))
-	s.cache.DeletePrefix(ctx, fmt.Sprintf("list:%s", session.Login))
+	legacyKeys := make(map[string]bool)
+	for _, v := range versions {
+		if v.Checksum != "" {
+			s.releaseBlob(ctx, v.Checksum)
+		} else if v.FilePath != "" {
+			legacyKeys[v.FilePath] = true
+		}
+	}
+	for key := range legacyKeys {
+		_ = s.fileStorage.Delete(ctx, key)
+	}
+
+	s.cache.DeletePrefix(ctx, fmt.Sprintf("doc:%s", doc.ID))
+	invalidateLists(ctx, s.cache, doc)
 
 	return nil
 }
+
+func canUpload(session *models.Session) bool {
+	return session.Role != models.RoleViewer && session.HasScope(models.ScopeWrite)
+}
+
+// permManage covers changing a document's name, visibility and ACL. It is
+// not a valid ACL permission, so only the owner ever holds it.
+const permManage = "manage"
+
+// permissionScopes maps each document permission to the API key scope it
+// needs.
+var permissionScopes = map[string]string{
+	models.PermRead:   models.ScopeRead,
+	models.PermWrite:  models.ScopeWrite,
+	models.PermShare:  models.ScopeWrite,
+	models.PermDelete: models.ScopeDelete,
+	permManage:        models.ScopeWrite,
+}
+
+// authorize is the access policy for documents; every operation on an
+// existing document is checked through it. The owner may do anything, admins
+// may read and delete, public documents are readable by all, and everyone
+// else needs an ACL entry for one of the session's principals. Viewers never
+// get to change a document, whatever their ACL entries say.
+func authorize(session *models.Session, doc *models.Document, perm string) bool {
+	if !session.HasScope(permissionScopes[perm]) {
+		return false
+	}
+	if perm != models.PermRead && perm != models.PermDelete && session.Role == models.RoleViewer {
+		return false
+	}
+	if doc.OwnerLogin == session.Login {
+		return true
+	}
+
+	switch perm {
+	case models.PermRead:
+		if doc.Public || session.IsAdmin() {
+			return true
+		}
+	case models.PermDelete:
+		if session.IsAdmin() {
+			return true
+		}
+	}
+
+	principals := session.Principals()
+	return slices.ContainsFunc(doc.ACL, func(e models.ACLEntry) bool {
+		return e.Allows(perm) && slices.Contains(principals, e.Grantee)
+	})
+}
+
+// normalizeACL merges entries for the same grantee and drops the owner, who
+// needs none. Every entry ends up with read, which any access implies.
+func normalizeACL(entries []models.ACLEntry, owner string) ([]models.ACLEntry, error) {
+	perms := make(map[string][]string)
+	for _, e := range entries {
+		grantee := strings.TrimSpace(e.Grantee)
+		if grantee == "" || grantee == models.GroupPrincipal("") || grantee == models.DirectoryPrincipal("") {
+			return nil, ErrInvalidGrant
+		}
+		for _, p := range e.Permissions {
+			if !models.ValidPermission(p) {
+				return nil, ErrInvalidGrant
+			}
+		}
+		if grantee != owner {
+			perms[grantee] = append(perms[grantee], e.Permissions...)
+		}
+	}
+
+	acl := make([]models.ACLEntry, 0, len(perms))
+	for grantee, p := range perms {
+		p = append(p, models.PermRead)
+		slices.Sort(p)
+		acl = append(acl, models.ACLEntry{Grantee: grantee, Permissions: slices.Compact(p)})
+	}
+	slices.SortFunc(acl, func(a, b models.ACLEntry) int { return strings.Compare(a.Grantee, b.Grantee) })
+	return acl, nil
+}
+
+// stagedMaxAge is how long a staged file may sit before it is taken for
+// left over. Staging only lasts from the end of an upload to its commit.
+const stagedMaxAge = time.Hour
+
+// RunStagedSweeper periodically deletes staged files that were never
+// committed, until ctx is cancelled.
+func (s *DocsService) RunStagedSweeper(ctx context.Context, interval time.Duration, onError func(error)) {
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.fileStorage.SweepStaged(ctx, time.Now().Add(-stagedMaxAge)); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// storeBlob writes the file to content-addressed storage and takes a
+// reference on its blob. The reference is taken before the data is moved into
+// place so that a concurrent release of the same blob cannot delete it
+// underneath us.
+func (s *DocsService) storeBlob(ctx context.Context, file io.Reader) (*models.Blob, error) {
+	blob, tmpKey, err := s.fileStorage.Stage(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.blobs.Acquire(ctx, blob); err != nil {
+		_ = s.fileStorage.Delete(ctx, tmpKey)
+		return nil, err
+	}
+
+	if err := s.fileStorage.Commit(ctx, tmpKey, blob); err != nil {
+		_ = s.fileStorage.Delete(ctx, tmpKey)
+		s.releaseBlob(ctx, blob.Checksum)
+		return nil, err
+	}
+
+	return blob, nil
+}
+
+func (s *DocsService) addBlobRef(ctx context.Context, checksum string) error {
+	if checksum == "" {
+		return nil
+	}
+	return s.blobs.AddRef(ctx, checksum)
+}
+
+func (s *DocsService) releaseBlob(ctx context.Context, checksum string) {
+	if checksum == "" {
+		return
+	}
+	_ = s.blobs.Release(ctx, checksum, func(key string) error {
+		return s.fileStorage.Delete(ctx, key)
+	})
+}
\ No newline at end of file
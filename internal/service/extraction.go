@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	models "docs_storage/internal/models"
+	extract "docs_storage/pkg/extract"
+)
+
+// maxExtractedText bounds the text kept per document, so that one large file
+// cannot bloat the search index.
+const maxExtractedText = 512 << 10
+
+type contentRepository interface {
+	GetByID(ctx context.Context, id string) (*models.Document, error)
+	MarkExtractionPending(ctx context.Context, id string, version int) error
+	ListPendingExtractions(ctx context.Context, age time.Duration, limit int) ([]models.ExtractionJob, error)
+	SaveExtraction(ctx context.Context, id string, version int, status, text, errMsg string) (bool, error)
+	DeleteExtraction(ctx context.Context, id string) error
+}
+
+type fileOpener interface {
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+}
+
+// Extractor gets the plain text out of uploaded files in the background and
+// stores it for search. Jobs are recorded as pending in the database before
+// they are queued, so those lost to a full queue or a restart are picked up
+// again by the sweep in Run.
+type Extractor struct {
+	docs    contentRepository
+	files   fileOpener
+	cache   cache
+	maxSize int64
+	queue   chan models.ExtractionJob
+}
+
+func NewExtractor(docs contentRepository, files fileOpener, c cache, queueSize int, maxSize int64) *Extractor {
+	return &Extractor{
+		docs:    docs,
+		files:   files,
+		cache:   c,
+		maxSize: maxSize,
+		queue:   make(chan models.ExtractionJob, max(queueSize, 1)),
+	}
+}
+
+// Enqueue asks for the text of the given version of a document. It never
+// blocks on the workers and never fails the upload that triggered it.
+func (e *Extractor) Enqueue(ctx context.Context, id string, version int) {
+	_ = e.docs.MarkExtractionPending(ctx, id, version)
+	e.offer(models.ExtractionJob{DocumentID: id, Version: version})
+}
+
+// Forget drops the text of a document whose current version has no file.
+func (e *Extractor) Forget(ctx context.Context, id string) {
+	_ = e.docs.DeleteExtraction(ctx, id)
+}
+
+func (e *Extractor) offer(job models.ExtractionJob) bool {
+	select {
+	case e.queue <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Run processes queued jobs with the given number of workers and every
+// sweepInterval requeues jobs that have been pending for longer than that,
+// until ctx is cancelled.
+func (e *Extractor) Run(ctx context.Context, workers int, sweepInterval time.Duration, onError func(error)) {
+	report := func(err error) {
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+
+	for range max(workers, 1) {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-e.queue:
+					report(e.process(ctx, job))
+				}
+			}
+		}()
+	}
+
+	// Jobs left pending by a previous run are due right away.
+	report(e.sweep(ctx, 0))
+	if sweepInterval <= 0 {
+		<-ctx.Done()
+		return
+	}
+
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report(e.sweep(ctx, sweepInterval))
+		}
+	}
+}
+
+func (e *Extractor) sweep(ctx context.Context, age time.Duration) error {
+	jobs, err := e.docs.ListPendingExtractions(ctx, age, cap(e.queue))
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if !e.offer(job) {
+			break
+		}
+	}
+	return nil
+}
+
+func (e *Extractor) process(ctx context.Context, job models.ExtractionJob) error {
+	doc, err := e.docs.GetByID(ctx, job.DocumentID)
+	if err != nil {
+		return err
+	}
+	// The document is gone or a newer version has its own job.
+	if doc == nil || doc.Version != job.Version {
+		return nil
+	}
+
+	status, errMsg := models.ExtractionDone, ""
+	text, err := e.text(ctx, doc)
+	switch {
+	case errors.Is(err, extract.ErrUnsupported):
+		status = models.ExtractionUnsupported
+	case err != nil:
+		status, errMsg = models.ExtractionFailed, err.Error()
+	}
+
+	saved, err := e.docs.SaveExtraction(ctx, doc.ID, job.Version, status, text, errMsg)
+	if err != nil {
+		return fmt.Errorf("save text of document %s: %w", doc.ID, err)
+	}
+	if !saved {
+		return nil
+	}
+
+	doc.ExtractionStatus = status
+	e.cache.Delete(ctx, fmt.Sprintf("doc:%s", doc.ID))
+	e.cache.Delete(ctx, fmt.Sprintf("%sdoc:%s", publicCachePrefix, doc.ID))
+	invalidateLists(ctx, e.cache, doc)
+	return nil
+}
+
+func (e *Extractor) text(ctx context.Context, doc *models.Document) (string, error) {
+	if doc.FilePath == "" || !extract.Supported(doc.Mime) {
+		return "", extract.ErrUnsupported
+	}
+
+	f, err := e.files.Open(ctx, doc.FilePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, e.maxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(data)) > e.maxSize {
+		return "", fmt.Errorf("file is larger than %d bytes", e.maxSize)
+	}
+
+	text, err := extract.Text(data, doc.Mime)
+	if err != nil {
+		return "", err
+	}
+	if len(text) > maxExtractedText {
+		text = strings.ToValidUTF8(text[:maxExtractedText], "")
+	}
+	return text, nil
+}
\ No newline at end of file
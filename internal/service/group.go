@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	models "docs_storage/internal/models"
+	repository "docs_storage/internal/repository"
+)
+
+var (
+	ErrInvalidGroupName = errors.New("group name must be 1-64 characters without ':' or ','")
+	ErrGroupExists      = errors.New("group already exists")
+)
+
+type groupRepository interface {
+	Create(ctx context.Context, g *models.Group) error
+	List(ctx context.Context) ([]models.Group, error)
+	GetByID(ctx context.Context, id int) (*models.Group, error)
+	Update(ctx context.Context, g *models.Group) error
+	Delete(ctx context.Context, id int) error
+	AddMember(ctx context.Context, groupID, userID int) error
+	RemoveMember(ctx context.Context, groupID, userID int) error
+}
+
+// GroupService manages local groups. Anyone signed in can see which groups
+// exist, so that they can share documents with them; changing groups is up
+// to admins.
+//
+// Membership changes apply to session tokens and API keys on their next
+// request. JWT access tokens carry the groups they were issued with until
+// they are refreshed.
+type GroupService struct {
+	groups   groupRepository
+	verifier tokenVerifier
+	cache    cache
+}
+
+func NewGroupService(groups groupRepository, verifier tokenVerifier, c cache) *GroupService {
+	return &GroupService{groups: groups, verifier: verifier, cache: c}
+}
+
+func (s *GroupService) List(ctx context.Context, token string) ([]models.Group, error) {
+	if _, err := s.verifier.Verify(ctx, token); err != nil {
+		return nil, err
+	}
+	return s.groups.List(ctx)
+}
+
+func (s *GroupService) Get(ctx context.Context, token string, id int) (*models.Group, error) {
+	if err := s.requireAdmin(ctx, token); err != nil {
+		return nil, err
+	}
+
+	g, err := s.groups.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, ErrNotFound
+	}
+	return g, nil
+}
+
+func (s *GroupService) Create(ctx context.Context, token, name, description string) (*models.Group, error) {
+	if err := s.requireAdmin(ctx, token); err != nil {
+		return nil, err
+	}
+	if !models.ValidGroupName(name) {
+		return nil, ErrInvalidGroupName
+	}
+
+	g := &models.Group{Name: name, Description: strings.TrimSpace(description)}
+	if err := s.groups.Create(ctx, g); err != nil {
+		return nil, groupError(err)
+	}
+	return g, nil
+}
+
+// Update changes the fields that are set. Renaming carries existing grants
+// over to the new name.
+func (s *GroupService) Update(ctx context.Context, token string, id int, name, description *string) (*models.Group, error) {
+	if err := s.requireAdmin(ctx, token); err != nil {
+		return nil, err
+	}
+	if name == nil && description == nil {
+		return nil, ErrEmptyUpdate
+	}
+
+	g, err := s.groups.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if g == nil {
+		return nil, ErrNotFound
+	}
+
+	renamed := name != nil && *name != g.Name
+	if name != nil {
+		if !models.ValidGroupName(*name) {
+			return nil, ErrInvalidGroupName
+		}
+		g.Name = *name
+	}
+	if description != nil {
+		g.Description = strings.TrimSpace(*description)
+	}
+
+	if err := s.groups.Update(ctx, g); err != nil {
+		return nil, groupError(err)
+	}
+	if renamed {
+		s.invalidateDocuments(ctx)
+	}
+	return g, nil
+}
+
+// Delete removes the group and revokes whatever was granted to it.
+func (s *GroupService) Delete(ctx context.Context, token string, id int) error {
+	if err := s.requireAdmin(ctx, token); err != nil {
+		return err
+	}
+	if err := s.groups.Delete(ctx, id); err != nil {
+		return groupError(err)
+	}
+	s.invalidateDocuments(ctx)
+	return nil
+}
+
+func (s *GroupService) AddMember(ctx context.Context, token string, groupID, userID int) error {
+	if err := s.requireAdmin(ctx, token); err != nil {
+		return err
+	}
+	return groupError(s.groups.AddMember(ctx, groupID, userID))
+}
+
+func (s *GroupService) RemoveMember(ctx context.Context, token string, groupID, userID int) error {
+	if err := s.requireAdmin(ctx, token); err != nil {
+		return err
+	}
+	return groupError(s.groups.RemoveMember(ctx, groupID, userID))
+}
+
+// invalidateDocuments drops cached documents and listings after ACL entries
+// were rewritten. Which documents named the group is not known here, and
+// renames and deletions are rare, so everything goes.
+//
+// Membership changes need no invalidation: listings are cached per set of
+// groups, and access to a cached document is checked on every read.
+func (s *GroupService) invalidateDocuments(ctx context.Context) {
+	s.cache.DeletePrefix(ctx, "doc:")
+	s.cache.DeletePrefix(ctx, "list:")
+}
+
+func (s *GroupService) requireAdmin(ctx context.Context, token string) error {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return err
+	}
+	if !session.IsAdmin() {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+func groupError(err error) error {
+	if errors.Is(err, repository.ErrGroupExists) {
+		return ErrGroupExists
+	}
+	return notFound(err)
+}
\ No newline at end of file
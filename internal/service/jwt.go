@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	models "docs_storage/internal/models"
+)
+
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+type accessClaims struct {
+	Login           string   `json:"login"`
+	Role            string   `json:"role"`
+	Groups          []string `json:"groups,omitempty"`
+	DirectoryGroups []string `json:"directory_groups,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// JWTManager issues and checks short-lived access tokens. They carry
+// everything a session lookup would return, so verifying one needs no
+// database round-trip; the price is that a token stays valid until it
+// expires even if the user is disabled in the meantime.
+type JWTManager struct {
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+	ttl       time.Duration
+}
+
+// NewJWTManager builds a manager for the given algorithm. For HS256 key is
+// the shared secret, for EdDSA it is a PEM-encoded Ed25519 private key.
+func NewJWTManager(algorithm string, key []byte, ttl time.Duration) (*JWTManager, error) {
+	if ttl <= 0 {
+		return nil, errors.New("jwt: access token lifetime must be positive")
+	}
+
+	m := &JWTManager{ttl: ttl}
+	switch algorithm {
+	case JWTAlgorithmHS256, "":
+		if len(key) == 0 {
+			return nil, errors.New("jwt: secret is empty")
+		}
+		m.method = jwt.SigningMethodHS256
+		m.signKey, m.verifyKey = key, key
+	case JWTAlgorithmEdDSA:
+		priv, err := jwt.ParseEdPrivateKeyFromPEM(key)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: %w", err)
+		}
+		edKey, ok := priv.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("jwt: not an Ed25519 private key")
+		}
+		m.method = jwt.SigningMethodEdDSA
+		m.signKey, m.verifyKey = edKey, edKey.Public()
+	default:
+		return nil, fmt.Errorf("jwt: unsupported algorithm %q", algorithm)
+	}
+	return m, nil
+}
+
+func (m *JWTManager) TTL() time.Duration {
+	return m.ttl
+}
+
+func (m *JWTManager) Issue(s *models.Session) (string, error) {
+	now := time.Now()
+	claims := accessClaims{
+		Login:           s.Login,
+		Role:            s.Role,
+		Groups:          s.Groups,
+		DirectoryGroups: s.DirectoryGroups,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(s.UserID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(m.ttl)),
+		},
+	}
+	return jwt.NewWithClaims(m.method, claims).SignedString(m.signKey)
+}
+
+func (m *JWTManager) Verify(_ context.Context, token string) (*models.Session, error) {
+	var claims accessClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return m.verifyKey, nil
+	}, jwt.WithValidMethods([]string{m.method.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return nil, ErrSessionExpired
+		}
+		return nil, ErrInvalidToken
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	session := &models.Session{
+		Token:           token,
+		UserID:          userID,
+		Login:           claims.Login,
+		Role:            claims.Role,
+		Groups:          claims.Groups,
+		DirectoryGroups: claims.DirectoryGroups,
+	}
+	if claims.IssuedAt != nil {
+		session.CreatedAt = claims.IssuedAt.Time
+		session.LastSeenAt = claims.IssuedAt.Time
+	}
+	return session, nil
+}
\ No newline at end of file
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+
+	models "docs_storage/internal/models"
+)
+
+const ldapTimeout = 10 * time.Second
+
+type LDAPConfig struct {
+	URL                string
+	StartTLS           bool
+	InsecureSkipVerify bool
+	// BindDN and BindPassword are the service account used to look users
+	// up; leave them empty for an anonymous search.
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	// UserFilter selects the user entry, with %s replaced by the escaped
+	// login, e.g. "(uid=%s)" or "(sAMAccountName=%s)".
+	UserFilter string
+	// LoginAttribute, if set and present on the entry, gives the canonical
+	// login, so that "Alice" and "alice" end up as the same user.
+	LoginAttribute string
+	// GroupAttribute lists the user's groups as DNs, usually memberOf.
+	GroupAttribute string
+	DefaultRole    string
+}
+
+// LDAPCredentials checks passwords by binding to an LDAP or Active Directory
+// server as the user.
+type LDAPCredentials struct {
+	cfg LDAPConfig
+}
+
+func NewLDAPCredentials(cfg LDAPConfig) *LDAPCredentials {
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(uid=%s)"
+	}
+	if cfg.GroupAttribute == "" {
+		cfg.GroupAttribute = "memberOf"
+	}
+	return &LDAPCredentials{cfg: cfg}
+}
+
+func (c *LDAPCredentials) VerifyCredentials(_ context.Context, login, pswd string) (*Identity, error) {
+	// An empty password would turn the bind into an unauthenticated one,
+	// which most servers accept.
+	if login == "" || pswd == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if c.cfg.BindDN != "" {
+		err = conn.Bind(c.cfg.BindDN, c.cfg.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldap: service bind: %w", err)
+	}
+
+	attrs := []string{c.cfg.GroupAttribute}
+	if c.cfg.LoginAttribute != "" {
+		attrs = append(attrs, c.cfg.LoginAttribute)
+	}
+	res, err := conn.Search(ldap.NewSearchRequest(
+		c.cfg.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout.Seconds()), false,
+		fmt.Sprintf(c.cfg.UserFilter, ldap.EscapeFilter(login)),
+		attrs,
+		nil,
+	))
+	if err != nil {
+		// More than the two entries asked for means the login is ambiguous,
+		// which is no more a match than two entries are.
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) || ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap: search: %w", err)
+	}
+	if len(res.Entries) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	entry := res.Entries[0]
+
+	if err := conn.Bind(entry.DN, pswd); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, fmt.Errorf("ldap: bind: %w", err)
+	}
+
+	if c.cfg.LoginAttribute != "" {
+		if v := entry.GetAttributeValue(c.cfg.LoginAttribute); v != "" {
+			login = v
+		}
+	}
+
+	return &Identity{
+		Login:    login,
+		External: true,
+		Source:   models.AuthSourceLDAP,
+		Role:     c.cfg.DefaultRole,
+		Groups:   groupNames(entry.GetAttributeValues(c.cfg.GroupAttribute)),
+	}, nil
+}
+
+func (c *LDAPCredentials) dial() (*ldap.Conn, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.cfg.InsecureSkipVerify}
+	if u, err := url.Parse(c.cfg.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+
+	conn, err := ldap.DialURL(c.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: ldapTimeout}),
+		ldap.DialWithTLSConfig(tlsConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("ldap: dial: %w", err)
+	}
+	conn.SetTimeout(ldapTimeout)
+
+	if c.cfg.StartTLS {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap: starttls: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+// groupNames reduces group DNs such as "cn=devs,ou=groups,dc=example,dc=com"
+// to their leading RDN value, "devs". Values that are not DNs are kept as is.
+func groupNames(values []string) []string {
+	groups := make([]string, 0, len(values))
+	for _, v := range values {
+		dn, err := ldap.ParseDN(v)
+		if err != nil || len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+			groups = append(groups, v)
+			continue
+		}
+		groups = append(groups, dn.RDNs[0].Attributes[0].Value)
+	}
+	return groups
+}
\ No newline at end of file
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+
+	models "docs_storage/internal/models"
+)
+
+const (
+	testBaseDN     = "dc=example,dc=com"
+	testServiceDN  = "cn=docs,ou=services,dc=example,dc=com"
+	testServicePwd = "service-secret"
+)
+
+type testEntry struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+// testDirectory is a minimal LDAP server: it answers simple binds and
+// searches with a single equality filter, honouring the size limit the way
+// real servers do.
+type testDirectory struct {
+	url     string
+	entries []testEntry
+
+	mu    sync.Mutex
+	binds []string
+}
+
+func newTestDirectory(t *testing.T, entries ...testEntry) *testDirectory {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	d := &testDirectory{url: "ldap://" + ln.Addr().String(), entries: entries}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go d.serve(conn)
+		}
+	}()
+	return d
+}
+
+// boundDNs returns the DNs binds were attempted for, service binds included.
+func (d *testDirectory) boundDNs() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.binds)
+}
+
+func (d *testDirectory) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		p, err := ber.ReadPacket(conn)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+		id, _ := p.Children[0].Value.(int64)
+		op := p.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			conn.Write(ldapMessage(id, ldapResult(ldap.ApplicationBindResponse, d.bind(op))).Bytes())
+		case ldap.ApplicationSearchRequest:
+			for _, resp := range d.search(op) {
+				conn.Write(ldapMessage(id, resp).Bytes())
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (d *testDirectory) bind(op *ber.Packet) uint16 {
+	dn, _ := op.Children[1].Value.(string)
+	pwd := op.Children[2].Data.String()
+
+	d.mu.Lock()
+	d.binds = append(d.binds, dn)
+	d.mu.Unlock()
+
+	switch {
+	case dn == "" && pwd == "":
+		return ldap.LDAPResultSuccess
+	case dn == testServiceDN && pwd == testServicePwd:
+		return ldap.LDAPResultSuccess
+	}
+	for _, e := range d.entries {
+		if strings.EqualFold(e.dn, dn) && pwd != "" && e.password == pwd {
+			return ldap.LDAPResultSuccess
+		}
+	}
+	return ldap.LDAPResultInvalidCredentials
+}
+
+func (d *testDirectory) search(op *ber.Packet) []*ber.Packet {
+	base, _ := op.Children[0].Value.(string)
+	limit, _ := op.Children[3].Value.(int64)
+	filter, err := ldap.DecompileFilter(op.Children[6])
+	if err != nil {
+		return []*ber.Packet{ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError)}
+	}
+	name, value, _ := strings.Cut(strings.Trim(filter, "()"), "=")
+
+	var out []*ber.Packet
+	for _, e := range d.entries {
+		if !strings.HasSuffix(strings.ToLower(e.dn), strings.ToLower(base)) {
+			continue
+		}
+		if !slices.ContainsFunc(e.attrs[name], func(v string) bool { return strings.EqualFold(v, value) }) {
+			continue
+		}
+		if limit > 0 && int64(len(out)) == limit {
+			return append(out, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSizeLimitExceeded))
+		}
+		out = append(out, ldapEntry(e))
+	}
+	return append(out, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+}
+
+func ldapMessage(id int64, op *ber.Packet) *ber.Packet {
+	msg := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+	msg.AppendChild(op)
+	return msg
+}
+
+func ldapResult(tag ber.Tag, code uint16) *ber.Packet {
+	res := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	res.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	res.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return res
+}
+
+func ldapEntry(e testEntry) *ber.Packet {
+	entry := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	entry.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "DN"))
+	attrs := ber.NewSequence("Attributes")
+	for name, values := range e.attrs {
+		attr := ber.NewSequence("Attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, v := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+		}
+		attr.AppendChild(set)
+		attrs.AppendChild(attr)
+	}
+	entry.AppendChild(attrs)
+	return entry
+}
+
+func person(uid, password, department string, groups ...string) testEntry {
+	return testEntry{
+		dn:       "uid=" + uid + ",ou=people," + testBaseDN,
+		password: password,
+		attrs: map[string][]string{
+			"uid":        {uid},
+			"department": {department},
+			"memberOf":   groups,
+		},
+	}
+}
+
+func testLDAPConfig(d *testDirectory) LDAPConfig {
+	return LDAPConfig{
+		URL:            d.url,
+		BindDN:         testServiceDN,
+		BindPassword:   testServicePwd,
+		BaseDN:         testBaseDN,
+		LoginAttribute: "uid",
+		DefaultRole:    models.RoleViewer,
+	}
+}
+
+func newPeopleDirectory(t *testing.T) *testDirectory {
+	return newTestDirectory(t,
+		person("alice", "alice-pwd", "sales", "cn=devs,ou=groups,dc=example,dc=com", "cn=ops,ou=groups,dc=example,dc=com"),
+		person("bob", "bob-pwd", "sales"),
+		person("carol", "carol-pwd", "sales"),
+		person("dave", "dave-pwd", "it"),
+	)
+}
+
+func TestLDAPVerifyCredentials(t *testing.T) {
+	d := newPeopleDirectory(t)
+	creds := NewLDAPCredentials(testLDAPConfig(d))
+
+	id, err := creds.VerifyCredentials(context.Background(), "Alice", "alice-pwd")
+	if err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	want := Identity{Login: "alice", External: true, Source: models.AuthSourceLDAP, Role: models.RoleViewer, Groups: []string{"devs", "ops"}}
+	if id.Login != want.Login || !id.External || id.Source != want.Source || id.Role != want.Role || !slices.Equal(id.Groups, want.Groups) {
+		t.Fatalf("got %+v, want %+v", *id, want)
+	}
+
+	tests := []struct {
+		name, login, pswd string
+	}{
+		{"wrong password", "alice", "bob-pwd"},
+		{"empty password", "alice", ""},
+		{"unknown user", "mallory", "alice-pwd"},
+		{"empty login", "", "alice-pwd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := creds.VerifyCredentials(context.Background(), tt.login, tt.pswd); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("got %v, want %v", err, ErrInvalidCredentials)
+			}
+		})
+	}
+}
+
+func TestLDAPEmptyPasswordNeverBinds(t *testing.T) {
+	d := newPeopleDirectory(t)
+	creds := NewLDAPCredentials(testLDAPConfig(d))
+
+	if _, err := creds.VerifyCredentials(context.Background(), "alice", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("got %v, want %v", err, ErrInvalidCredentials)
+	}
+	if binds := d.boundDNs(); len(binds) != 0 {
+		t.Fatalf("directory saw binds %q", binds)
+	}
+}
+
+func TestLDAPAmbiguousLogin(t *testing.T) {
+	d := newPeopleDirectory(t)
+	cfg := testLDAPConfig(d)
+	cfg.UserFilter = "(department=%s)"
+	creds := NewLDAPCredentials(cfg)
+
+	// Three entries match: the server stops at the size limit of two.
+	if _, err := creds.VerifyCredentials(context.Background(), "sales", "alice-pwd"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("size limit: got %v, want %v", err, ErrInvalidCredentials)
+	}
+	// Exactly one does.
+	if _, err := creds.VerifyCredentials(context.Background(), "it", "dave-pwd"); err != nil {
+		t.Fatalf("single match: %v", err)
+	}
+}
+
+func TestLDAPServiceBindFailure(t *testing.T) {
+	d := newPeopleDirectory(t)
+	cfg := testLDAPConfig(d)
+	cfg.BindPassword = "wrong"
+	creds := NewLDAPCredentials(cfg)
+
+	_, err := creds.VerifyCredentials(context.Background(), "alice", "alice-pwd")
+	if err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("got %v, want a service bind error", err)
+	}
+}
\ No newline at end of file
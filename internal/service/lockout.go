@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	models "docs_storage/internal/models"
+)
+
+var (
+	ErrTooManyAttempts    = errors.New("too many failed login attempts")
+	ErrInvalidLockoutKind = errors.New("lockout kind must be login or ip")
+)
+
+// LockedError is returned while a login or address is blocked. It matches
+// ErrTooManyAttempts with errors.Is.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s, retry in %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrTooManyAttempts
+}
+
+const lockoutPrefix = "lockout:"
+
+// attemptStore keeps failure counters. The in-process LFUCache satisfies it;
+// a shared store lets several instances see each other's counters.
+type attemptStore interface {
+	Get(ctx context.Context, key string) (any, bool)
+	Set(ctx context.Context, key string, value any)
+	Delete(ctx context.Context, key string)
+	Keys(ctx context.Context, prefix string) []string
+}
+
+type LockoutPolicy struct {
+	// After the free attempts every further failure blocks the login or
+	// address for BaseDelay, doubling each time up to MaxDelay. Addresses
+	// get more free attempts since many users may share one.
+	LoginFreeAttempts int
+	IPFreeAttempts    int
+	BaseDelay         time.Duration
+	MaxDelay          time.Duration
+	// Window is how long a counter survives without new failures.
+	Window time.Duration
+}
+
+type attemptRecord struct {
+	Failures     int
+	LastFailure  time.Time
+	BlockedUntil time.Time
+}
+
+// LoginLimiter counts failed logins per login name and per client address
+// and slows down or blocks further attempts.
+type LoginLimiter struct {
+	store  attemptStore
+	policy LockoutPolicy
+
+	// Serialises read-modify-write of counters within this process.
+	mu sync.Mutex
+}
+
+func NewLoginLimiter(store attemptStore, policy LockoutPolicy) *LoginLimiter {
+	return &LoginLimiter{store: store, policy: policy}
+}
+
+// Reserve counts an attempt as failed before the password is checked, so
+// that concurrent requests cannot all get past low counters. It returns a
+// *LockedError, counting nothing, if either the login or the address may not
+// try again yet. An attempt that succeeds or ends without a verdict gives the
+// reservation back with Success or Release.
+func (l *LoginLimiter) Reserve(ctx context.Context, login, ip string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	keys := l.keys(login, ip)
+	recs := make(map[string]attemptRecord, len(keys))
+	var wait time.Duration
+	for kind, key := range keys {
+		rec, _ := l.get(ctx, key, now)
+		if rec.BlockedUntil.After(now) {
+			wait = max(wait, rec.BlockedUntil.Sub(now))
+		}
+		recs[kind] = rec
+	}
+	if wait > 0 {
+		return &LockedError{RetryAfter: wait}
+	}
+
+	for kind, rec := range recs {
+		rec.Failures++
+		rec.LastFailure = now
+		if over := rec.Failures - l.free(kind); over > 0 {
+			rec.BlockedUntil = now.Add(l.delay(over))
+		}
+		l.store.Set(ctx, keys[kind], rec)
+	}
+	return nil
+}
+
+// Release gives back a reservation whose attempt could not check the
+// password, e.g. because the directory was unreachable.
+func (l *LoginLimiter) Release(ctx context.Context, login, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for kind, key := range l.keys(login, ip) {
+		l.release(ctx, kind, key, now)
+	}
+}
+
+// Success forgets the login's failures and gives back the address's
+// reservation. The rest of the address counter is kept, so that logging into
+// one's own account does not reset a spraying attempt.
+func (l *LoginLimiter) Success(ctx context.Context, login, ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.store.Delete(ctx, lockoutKey(models.LockoutLogin, strings.ToLower(login)))
+	if ip != "" {
+		l.release(ctx, models.LockoutIP, lockoutKey(models.LockoutIP, ip), time.Now())
+	}
+}
+
+// List returns the logins and addresses that are blocked right now.
+func (l *LoginLimiter) List(ctx context.Context) []models.Lockout {
+	now := time.Now()
+	var out []models.Lockout
+	for _, key := range l.store.Keys(ctx, lockoutPrefix) {
+		rec, ok := l.get(ctx, key, now)
+		if !ok || !rec.BlockedUntil.After(now) {
+			continue
+		}
+		kind, value, _ := strings.Cut(strings.TrimPrefix(key, lockoutPrefix), ":")
+		out = append(out, models.Lockout{Kind: kind, Value: value, Failures: rec.Failures, BlockedUntil: rec.BlockedUntil})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].BlockedUntil.After(out[j].BlockedUntil) })
+	return out
+}
+
+func (l *LoginLimiter) Clear(ctx context.Context, kind, value string) {
+	if kind == models.LockoutLogin {
+		value = strings.ToLower(value)
+	}
+	l.store.Delete(ctx, lockoutKey(kind, value))
+}
+
+// release undoes one reservation. A block is lifted only once the counter is
+// back within the free attempts, as other failures may have earned it.
+func (l *LoginLimiter) release(ctx context.Context, kind, key string, now time.Time) {
+	rec, ok := l.get(ctx, key, now)
+	if !ok {
+		return
+	}
+	rec.Failures--
+	if rec.Failures <= 0 {
+		l.store.Delete(ctx, key)
+		return
+	}
+	if rec.Failures <= l.free(kind) {
+		rec.BlockedUntil = time.Time{}
+	}
+	l.store.Set(ctx, key, rec)
+}
+
+func (l *LoginLimiter) free(kind string) int {
+	if kind == models.LockoutIP {
+		return l.policy.IPFreeAttempts
+	}
+	return l.policy.LoginFreeAttempts
+}
+
+func (l *LoginLimiter) delay(over int) time.Duration {
+	d := l.policy.BaseDelay
+	for i := 1; i < over && d < l.policy.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, l.policy.MaxDelay)
+}
+
+func (l *LoginLimiter) get(ctx context.Context, key string, now time.Time) (attemptRecord, bool) {
+	v, ok := l.store.Get(ctx, key)
+	if !ok {
+		return attemptRecord{}, false
+	}
+	rec, ok := v.(attemptRecord)
+	if !ok || (now.Sub(rec.LastFailure) > l.policy.Window && !rec.BlockedUntil.After(now)) {
+		return attemptRecord{}, false
+	}
+	return rec, true
+}
+
+func (l *LoginLimiter) keys(login, ip string) map[string]string {
+	keys := map[string]string{models.LockoutLogin: lockoutKey(models.LockoutLogin, strings.ToLower(login))}
+	if ip != "" {
+		keys[models.LockoutIP] = lockoutKey(models.LockoutIP, ip)
+	}
+	return keys
+}
+
+func lockoutKey(kind, value string) string {
+	return lockoutPrefix + kind + ":" + value
+}
+
+// LockoutService lets admins see and lift lockouts.
+type LockoutService struct {
+	limiter  *LoginLimiter
+	verifier tokenVerifier
+}
+
+func NewLockoutService(limiter *LoginLimiter, verifier tokenVerifier) *LockoutService {
+	return &LockoutService{limiter: limiter, verifier: verifier}
+}
+
+func (s *LockoutService) List(ctx context.Context, token string) ([]models.Lockout, error) {
+	if err := s.requireAdmin(ctx, token); err != nil {
+		return nil, err
+	}
+	return s.limiter.List(ctx), nil
+}
+
+func (s *LockoutService) Clear(ctx context.Context, token, kind, value string) error {
+	if err := s.requireAdmin(ctx, token); err != nil {
+		return err
+	}
+	if kind != models.LockoutLogin && kind != models.LockoutIP || value == "" {
+		return ErrInvalidLockoutKind
+	}
+	s.limiter.Clear(ctx, kind, value)
+	return nil
+}
+
+func (s *LockoutService) requireAdmin(ctx context.Context, token string) error {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return err
+	}
+	if !session.IsAdmin() {
+		return ErrAccessDenied
+	}
+	return nil
+}
\ No newline at end of file
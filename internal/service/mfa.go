@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	models "docs_storage/internal/models"
+	repository "docs_storage/internal/repository"
+	"docs_storage/pkg/totp"
+)
+
+// MFAChallengeTTL is how long a login may wait for its second factor.
+const MFAChallengeTTL = 5 * time.Minute
+
+const (
+	recoveryCodeCount = 10
+	maxMFAAttempts    = 5
+	// totpSkew tolerates one period of clock drift on the user's device.
+	totpSkew = 1
+)
+
+var (
+	ErrMFAChallenge       = errors.New("mfa challenge invalid or expired")
+	ErrInvalidMFACode     = errors.New("invalid verification code")
+	ErrTOTPNotEnrolled    = errors.New("two-factor authentication is not enrolled")
+	ErrTOTPAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+)
+
+type totpRepository interface {
+	GetTOTP(ctx context.Context, userID int) (*models.TOTP, error)
+	AdvanceTOTPStep(ctx context.Context, userID int, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID int, code string) (bool, error)
+}
+
+type mfaRepository interface {
+	totpRepository
+	SetTOTPSecret(ctx context.Context, userID int, secret string) error
+	EnableTOTP(ctx context.Context, userID int, recoveryCodes []string) error
+	ResetTOTP(ctx context.Context, userID int) error
+}
+
+// MFAService lets users manage their own TOTP second factor and admins
+// remove it for users who lost their device.
+type MFAService struct {
+	mfa      mfaRepository
+	verifier tokenVerifier
+	issuer   string
+}
+
+func NewMFAService(mfa mfaRepository, verifier tokenVerifier, issuer string) *MFAService {
+	return &MFAService{mfa: mfa, verifier: verifier, issuer: issuer}
+}
+
+// Enroll generates a new secret for the caller. It takes effect only after
+// Confirm, so an abandoned enrollment does not lock anyone out.
+func (s *MFAService) Enroll(ctx context.Context, token string) (secret, uri string, err error) {
+	session, err := s.interactiveSession(ctx, token)
+	if err != nil {
+		return "", "", err
+	}
+
+	t, err := s.mfa.GetTOTP(ctx, session.UserID)
+	if err != nil {
+		return "", "", err
+	}
+	if t != nil && t.Enabled {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+
+	secret, err = totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.mfa.SetTOTPSecret(ctx, session.UserID, secret); err != nil {
+		return "", "", err
+	}
+	return secret, totp.URI(s.issuer, session.Login, secret), nil
+}
+
+// Confirm enables the enrolled secret once the user proves they can produce
+// codes for it, and returns fresh recovery codes. They are shown only once.
+func (s *MFAService) Confirm(ctx context.Context, token, code string) ([]string, error) {
+	session, err := s.interactiveSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := s.mfa.GetTOTP(ctx, session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, ErrTOTPNotEnrolled
+	}
+	if t.Enabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+
+	step, ok := totp.Validate(t.Secret, code, time.Now(), totpSkew)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	if _, err := s.mfa.AdvanceTOTPStep(ctx, session.UserID, step); err != nil {
+		return nil, err
+	}
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfa.EnableTOTP(ctx, session.UserID, codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable turns the caller's second factor off. It takes a current code or a
+// recovery code, so that a stolen session alone is not enough.
+func (s *MFAService) Disable(ctx context.Context, token, code string) error {
+	session, err := s.interactiveSession(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	t, err := s.mfa.GetTOTP(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+	if t == nil || !t.Enabled {
+		return ErrTOTPNotEnrolled
+	}
+	if err := checkSecondFactor(ctx, s.mfa, t, code); err != nil {
+		return err
+	}
+	return s.mfa.ResetTOTP(ctx, session.UserID)
+}
+
+// Reset removes a user's second factor on an admin's behalf.
+func (s *MFAService) Reset(ctx context.Context, token string, userID int) error {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return err
+	}
+	if !session.IsAdmin() {
+		return ErrAccessDenied
+	}
+
+	if err := s.mfa.ResetTOTP(ctx, userID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *MFAService) interactiveSession(ctx context.Context, token string) (*models.Session, error) {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if session.IsAPIKey() {
+		return nil, ErrAccessDenied
+	}
+	return session, nil
+}
+
+// checkSecondFactor accepts either a TOTP code not used before or an unused
+// recovery code, consuming it.
+func checkSecondFactor(ctx context.Context, repo totpRepository, t *models.TOTP, code string) error {
+	if step, ok := totp.Validate(t.Secret, code, time.Now(), totpSkew); ok {
+		fresh, err := repo.AdvanceTOTPStep(ctx, t.UserID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	used, err := repo.UseRecoveryCode(ctx, t.UserID, normalizeRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func newRecoveryCodes() ([]string, error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		c := strings.ToLower(enc.EncodeToString(buf))
+		codes[i] = c[:4] + "-" + c[4:]
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode lets users type codes without the dash or in upper
+// case.
+func normalizeRecoveryCode(code string) string {
+	c := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	if len(c) != 8 {
+		return c
+	}
+	return c[:4] + "-" + c[4:]
+}
\ No newline at end of file
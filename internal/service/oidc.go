@@ -0,0 +1,270 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	models "docs_storage/internal/models"
+	repository "docs_storage/internal/repository"
+)
+
+var (
+	ErrOIDCState         = errors.New("oidc: state mismatch")
+	ErrOIDCNoLogin       = errors.New("oidc: id token has neither preferred_username nor a verified email")
+	ErrOIDCAccountExists = errors.New("oidc: an account with this login exists and is not linked to this identity provider")
+	ErrOIDCIdentityTaken = errors.New("oidc: identity is linked to another user")
+	ErrOIDCNoSubject     = errors.New("oidc: subject required")
+)
+
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	DefaultRole  string
+}
+
+// OIDCFlow is what has to survive between the redirect to the identity
+// provider and the callback. The handler keeps it in a cookie.
+type OIDCFlow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+type loginFinisher interface {
+	FinishLogin(ctx context.Context, u *models.User) (*AuthTokens, error)
+}
+
+type identityRepository interface {
+	GetByLogin(ctx context.Context, login string) (*models.User, error)
+	GetByIdentity(ctx context.Context, issuer, subject string) (*models.User, error)
+	CreateWithIdentity(ctx context.Context, u *models.User, issuer, subject string) error
+	LinkIdentity(ctx context.Context, userID int, issuer, subject string) error
+	UnlinkIdentities(ctx context.Context, userID int, issuer string) error
+}
+
+// OIDCService logs users in through an external identity provider using the
+// authorization code flow with PKCE. Users are matched by the provider's
+// issuer and subject and created on their first visit; an existing account
+// is only reachable through SSO once an admin has linked it.
+type OIDCService struct {
+	cfg      OIDCConfig
+	users    identityRepository
+	logins   loginFinisher
+	auth     tokenVerifier
+
+	// The provider is discovered on first use rather than at startup, so
+	// that the service comes up even while the identity provider is down.
+	mu       sync.Mutex
+	provider *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+	oauth    *oauth2.Config
+}
+
+func NewOIDCService(cfg OIDCConfig, users identityRepository, logins loginFinisher, auth tokenVerifier) *OIDCService {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"profile", "email"}
+	}
+	return &OIDCService{cfg: cfg, users: users, logins: logins, auth: auth}
+}
+
+// LoginURL starts a login and returns the provider URL to redirect to along
+// with the flow state the callback will need.
+func (s *OIDCService) LoginURL(ctx context.Context) (string, *OIDCFlow, error) {
+	conf, _, err := s.client(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	flow := &OIDCFlow{Verifier: oauth2.GenerateVerifier()}
+	if flow.State, err = randomString(); err != nil {
+		return "", nil, err
+	}
+	if flow.Nonce, err = randomString(); err != nil {
+		return "", nil, err
+	}
+
+	url := conf.AuthCodeURL(flow.State,
+		oidc.Nonce(flow.Nonce),
+		oauth2.S256ChallengeOption(flow.Verifier),
+	)
+	return url, flow, nil
+}
+
+// Callback completes a login: it redeems the code, checks the ID token and
+// logs the matching user in, creating the user if needed. Users with a
+// second factor get an MFA challenge to complete like after a password.
+func (s *OIDCService) Callback(ctx context.Context, flow *OIDCFlow, state, code string) (*AuthTokens, error) {
+	if flow == nil || flow.State == "" || flow.State != state {
+		return nil, ErrOIDCState
+	}
+
+	conf, verifier, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tok, err := conf.Exchange(ctx, code, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("oidc: exchange code: %w", err)
+	}
+	rawID, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("oidc: token response has no id_token")
+	}
+	idToken, err := verifier.Verify(ctx, rawID)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+	if idToken.Nonce != flow.Nonce {
+		return nil, errors.New("oidc: nonce mismatch")
+	}
+
+	var claims struct {
+		PreferredUsername string `json:"preferred_username"`
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+	if idToken.Subject == "" {
+		return nil, errors.New("oidc: id token has no subject")
+	}
+	login := strings.TrimSpace(claims.PreferredUsername)
+	if login == "" && claims.EmailVerified {
+		login = strings.TrimSpace(claims.Email)
+	}
+
+	u, err := s.account(ctx, idToken.Issuer, idToken.Subject, login)
+	if err != nil {
+		return nil, err
+	}
+	// The provider only stands in for the password: a second factor is
+	// still asked for.
+	return s.logins.FinishLogin(ctx, u)
+}
+
+// account returns the user linked to the identity, creating one named login
+// on its first visit. An identity never takes over an existing account of
+// the same name, as anyone able to pick that name at the provider would get
+// in as its owner.
+func (s *OIDCService) account(ctx context.Context, issuer, subject, login string) (*models.User, error) {
+	u, err := s.users.GetByIdentity(ctx, issuer, subject)
+	if err != nil || u != nil {
+		return u, err
+	}
+
+	if login == "" {
+		return nil, ErrOIDCNoLogin
+	}
+	existing, err := s.users.GetByLogin(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrOIDCAccountExists
+	}
+
+	role := s.cfg.DefaultRole
+	if !models.ValidRole(role) {
+		role = models.RoleEditor
+	}
+	createErr := s.users.CreateWithIdentity(ctx, &models.User{Login: login, Role: role, AuthSource: models.AuthSourceOIDC}, issuer, subject)
+
+	// Re-read even if creation failed: a concurrent first login may have
+	// created the user in the meantime.
+	u, err = s.users.GetByIdentity(ctx, issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		if errors.Is(createErr, repository.ErrUserExists) {
+			return nil, ErrOIDCAccountExists
+		}
+		if createErr != nil {
+			return nil, createErr
+		}
+		return nil, ErrNotFound
+	}
+	return u, nil
+}
+
+// Link lets the account with the given subject at the identity provider log
+// in as the user, which is how an existing account is connected to SSO.
+// Only admins may link.
+func (s *OIDCService) Link(ctx context.Context, token string, userID int, subject string) error {
+	if err := s.requireAdmin(ctx, token); err != nil {
+		return err
+	}
+	if subject == "" {
+		return ErrOIDCNoSubject
+	}
+
+	err := s.users.LinkIdentity(ctx, userID, s.cfg.IssuerURL, subject)
+	if errors.Is(err, repository.ErrIdentityTaken) {
+		return ErrOIDCIdentityTaken
+	}
+	return notFound(err)
+}
+
+// Unlink disconnects the user from the identity provider.
+func (s *OIDCService) Unlink(ctx context.Context, token string, userID int) error {
+	if err := s.requireAdmin(ctx, token); err != nil {
+		return err
+	}
+	return notFound(s.users.UnlinkIdentities(ctx, userID, s.cfg.IssuerURL))
+}
+
+func (s *OIDCService) requireAdmin(ctx context.Context, token string) error {
+	session, err := s.auth.Verify(ctx, token)
+	if err != nil {
+		return err
+	}
+	if !session.IsAdmin() {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+func (s *OIDCService) client(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider == nil {
+		// Discovery must not be tied to the request that happened to
+		// trigger it, the provider keeps using the context for key refresh.
+		provider, err := oidc.NewProvider(context.WithoutCancel(ctx), s.cfg.IssuerURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("oidc: discover provider: %w", err)
+		}
+		s.provider = provider
+		s.verifier = provider.Verifier(&oidc.Config{ClientID: s.cfg.ClientID})
+		s.oauth = &oauth2.Config{
+			ClientID:     s.cfg.ClientID,
+			ClientSecret: s.cfg.ClientSecret,
+			RedirectURL:  s.cfg.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       append([]string{oidc.ScopeOpenID}, s.cfg.Scopes...),
+		}
+	}
+	return s.oauth, s.verifier, nil
+}
+
+func randomString() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
\ No newline at end of file
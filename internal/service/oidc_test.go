@@ -0,0 +1,367 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	models "docs_storage/internal/models"
+	repository "docs_storage/internal/repository"
+)
+
+const testClientID = "docs"
+
+// mockIdP is an OpenID provider serving discovery, its signing keys and a
+// token endpoint that checks the PKCE verifier.
+type mockIdP struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockGrant
+}
+
+type mockGrant struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: key, codes: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                idp.URL,
+			"authorization_endpoint":                idp.URL + "/authorize",
+			"token_endpoint":                        idp.URL + "/token",
+			"jwks_uri":                              idp.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	idp.mu.Lock()
+	grant, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, grant.claims)
+	tok.Header["kid"] = "test"
+	idToken, err := tok.SignedString(idp.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// authorize plays the user's visit to the provider: it takes the login URL
+// handed out by the service and returns the code the provider redirects
+// back with, issued for the given subject and claims.
+func (idp *mockIdP) authorize(t *testing.T, loginURL, subject string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("login URL has no S256 code challenge: %s", loginURL)
+	}
+
+	all := jwt.MapClaims{
+		"iss":   idp.URL,
+		"sub":   subject,
+		"aud":   testClientID,
+		"nonce": q.Get("nonce"),
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		all[k] = v
+	}
+
+	code := rand.Text()
+	idp.mu.Lock()
+	idp.codes[code] = mockGrant{challenge: q.Get("code_challenge"), claims: all}
+	idp.mu.Unlock()
+	return code
+}
+
+// memIdentities keeps users and their identities in memory.
+type memIdentities struct {
+	users      []*models.User
+	identities map[[2]string]int
+}
+
+func newMemIdentities(users ...*models.User) *memIdentities {
+	r := &memIdentities{identities: make(map[[2]string]int)}
+	for _, u := range users {
+		r.add(u)
+	}
+	return r
+}
+
+func (r *memIdentities) add(u *models.User) {
+	u.ID = len(r.users) + 1
+	r.users = append(r.users, u)
+}
+
+func (r *memIdentities) GetByLogin(_ context.Context, login string) (*models.User, error) {
+	for _, u := range r.users {
+		if u.Login == login {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memIdentities) GetByIdentity(_ context.Context, issuer, subject string) (*models.User, error) {
+	id, ok := r.identities[[2]string{issuer, subject}]
+	if !ok {
+		return nil, nil
+	}
+	return r.users[id-1], nil
+}
+
+func (r *memIdentities) CreateWithIdentity(ctx context.Context, u *models.User, issuer, subject string) error {
+	if existing, _ := r.GetByLogin(ctx, u.Login); existing != nil {
+		return repository.ErrUserExists
+	}
+	r.add(u)
+	return r.LinkIdentity(ctx, u.ID, issuer, subject)
+}
+
+func (r *memIdentities) LinkIdentity(_ context.Context, userID int, issuer, subject string) error {
+	if id, ok := r.identities[[2]string{issuer, subject}]; ok && id != userID {
+		return repository.ErrIdentityTaken
+	}
+	r.identities[[2]string{issuer, subject}] = userID
+	return nil
+}
+
+func (r *memIdentities) UnlinkIdentities(_ context.Context, userID int, issuer string) error {
+	for k, id := range r.identities {
+		if k[0] == issuer && id == userID {
+			delete(r.identities, k)
+		}
+	}
+	return nil
+}
+
+// loginRecorder stands in for AuthService and hands out the login as token.
+type loginRecorder struct{}
+
+func (loginRecorder) FinishLogin(_ context.Context, u *models.User) (*AuthTokens, error) {
+	return &AuthTokens{Token: u.Login}, nil
+}
+
+func newTestOIDC(idp *mockIdP, users *memIdentities) *OIDCService {
+	return NewOIDCService(OIDCConfig{
+		IssuerURL:   idp.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://docs.test/api/auth/oidc/callback",
+		DefaultRole: models.RoleViewer,
+	}, users, loginRecorder{}, nil)
+}
+
+// oidcLogin runs a whole login for subject with the given claims.
+func oidcLogin(t *testing.T, idp *mockIdP, svc *OIDCService, subject string, claims jwt.MapClaims) (*AuthTokens, error) {
+	t.Helper()
+
+	loginURL, flow, err := svc.LoginURL(context.Background())
+	if err != nil {
+		t.Fatalf("LoginURL: %v", err)
+	}
+	code := idp.authorize(t, loginURL, subject, claims)
+	return svc.Callback(context.Background(), flow, flow.State, code)
+}
+
+func TestOIDCCallbackProvisionsByIssuerAndSubject(t *testing.T) {
+	idp := newMockIdP(t)
+	users := newMemIdentities()
+	svc := newTestOIDC(idp, users)
+
+	tokens, err := oidcLogin(t, idp, svc, "sub-1", jwt.MapClaims{"preferred_username": "alice"})
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if tokens.Token != "alice" {
+		t.Fatalf("logged in as %q, want alice", tokens.Token)
+	}
+
+	u, _ := users.GetByIdentity(context.Background(), idp.URL, "sub-1")
+	if u == nil {
+		t.Fatal("identity was not recorded")
+	}
+	if u.Role != models.RoleViewer || u.AuthSource != models.AuthSourceOIDC || u.Password != "" {
+		t.Fatalf("created user %+v", u)
+	}
+
+	// A renamed account at the provider is still the same user.
+	tokens, err = oidcLogin(t, idp, svc, "sub-1", jwt.MapClaims{"preferred_username": "alice.renamed"})
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if tokens.Token != "alice" || len(users.users) != 1 {
+		t.Fatalf("renamed identity logged in as %q with %d users", tokens.Token, len(users.users))
+	}
+}
+
+func TestOIDCCallbackEmailFallback(t *testing.T) {
+	idp := newMockIdP(t)
+	svc := newTestOIDC(idp, newMemIdentities())
+
+	tokens, err := oidcLogin(t, idp, svc, "sub-1", jwt.MapClaims{"email": "bob@example.com", "email_verified": true})
+	if err != nil {
+		t.Fatalf("verified email: %v", err)
+	}
+	if tokens.Token != "bob@example.com" {
+		t.Fatalf("logged in as %q", tokens.Token)
+	}
+
+	_, err = oidcLogin(t, idp, svc, "sub-2", jwt.MapClaims{"email": "eve@example.com"})
+	if !errors.Is(err, ErrOIDCNoLogin) {
+		t.Fatalf("unverified email: got %v, want %v", err, ErrOIDCNoLogin)
+	}
+}
+
+func TestOIDCCallbackRefusesExistingLocalAccount(t *testing.T) {
+	idp := newMockIdP(t)
+	admin := &models.User{Login: "admin", Password: "hash", Role: models.RoleAdmin, AuthSource: models.AuthSourceLocal}
+	users := newMemIdentities(admin)
+	svc := newTestOIDC(idp, users)
+
+	_, err := oidcLogin(t, idp, svc, "attacker", jwt.MapClaims{"preferred_username": "admin"})
+	if !errors.Is(err, ErrOIDCAccountExists) {
+		t.Fatalf("got %v, want %v", err, ErrOIDCAccountExists)
+	}
+	if len(users.identities) != 0 {
+		t.Fatal("identity was linked to the existing account")
+	}
+
+	// Once an admin has linked the identity, it logs into the account.
+	if err := users.LinkIdentity(context.Background(), admin.ID, idp.URL, "owner"); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := oidcLogin(t, idp, svc, "owner", jwt.MapClaims{"preferred_username": "someone-else"})
+	if err != nil {
+		t.Fatalf("linked login: %v", err)
+	}
+	if tokens.Token != "admin" {
+		t.Fatalf("linked identity logged in as %q", tokens.Token)
+	}
+}
+
+func TestOIDCCallbackChecksState(t *testing.T) {
+	idp := newMockIdP(t)
+	svc := newTestOIDC(idp, newMemIdentities())
+
+	loginURL, flow, err := svc.LoginURL(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := idp.authorize(t, loginURL, "sub-1", jwt.MapClaims{"preferred_username": "alice"})
+
+	if _, err := svc.Callback(context.Background(), flow, "forged", code); !errors.Is(err, ErrOIDCState) {
+		t.Fatalf("wrong state: got %v, want %v", err, ErrOIDCState)
+	}
+	if _, err := svc.Callback(context.Background(), nil, flow.State, code); !errors.Is(err, ErrOIDCState) {
+		t.Fatalf("no flow: got %v, want %v", err, ErrOIDCState)
+	}
+}
+
+func TestOIDCCallbackChecksPKCEVerifier(t *testing.T) {
+	idp := newMockIdP(t)
+	users := newMemIdentities()
+	svc := newTestOIDC(idp, users)
+
+	loginURL, flow, err := svc.LoginURL(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := idp.authorize(t, loginURL, "sub-1", jwt.MapClaims{"preferred_username": "alice"})
+
+	// A code intercepted on its way back is useless without the verifier
+	// from the flow cookie.
+	stolen := *flow
+	stolen.Verifier = "not-the-verifier-not-the-verifier-not-the-verifier"
+	if _, err := svc.Callback(context.Background(), &stolen, flow.State, code); err == nil {
+		t.Fatal("code was redeemed with a wrong verifier")
+	}
+	if len(users.users) != 0 {
+		t.Fatal("user was created")
+	}
+}
+
+func TestOIDCCallbackChecksNonce(t *testing.T) {
+	idp := newMockIdP(t)
+	svc := newTestOIDC(idp, newMemIdentities())
+
+	loginURL, flow, err := svc.LoginURL(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := idp.authorize(t, loginURL, "sub-1", jwt.MapClaims{"preferred_username": "alice", "nonce": "replayed"})
+
+	if _, err := svc.Callback(context.Background(), flow, flow.State, code); err == nil {
+		t.Fatal("id token with a foreign nonce was accepted")
+	}
+}
\ No newline at end of file
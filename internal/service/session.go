@@ -0,0 +1,21 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	models "docs_storage/internal/models"
+	repository "docs_storage/internal/repository"
+)
+
+var (
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrSessionExpired = repository.ErrSessionExpired
+)
+
+// tokenVerifier resolves a bearer token to its session. Unknown tokens yield
+// ErrInvalidToken and expired ones ErrSessionExpired, so that clients can
+// tell when they only need to log in again.
+type tokenVerifier interface {
+	Verify(ctx context.Context, token string) (*models.Session, error)
+}
\ No newline at end of file
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	models "docs_storage/internal/models"
+)
+
+var (
+	ErrInvalidShareLink  = errors.New("invalid expiry or download limit")
+	ErrShareLinkExpired  = errors.New("share link has expired or reached its download limit")
+	ErrShareLinkPassword = errors.New("share link password required or wrong")
+)
+
+type shareLinkRepository interface {
+	Create(ctx context.Context, l *models.ShareLink) error
+	ListByCreator(ctx context.Context, login string) ([]models.ShareLink, error)
+	GetByID(ctx context.Context, id string) (*models.ShareLink, error)
+	Consume(ctx context.Context, id string) (bool, error)
+	Delete(ctx context.Context, id, owner string) error
+}
+
+type documentGetter interface {
+	GetByID(ctx context.Context, id string) (*models.Document, error)
+}
+
+type ShareLinkPolicy struct {
+	// Secret signs the link tokens. Changing it invalidates every link.
+	Secret []byte
+	// DefaultTTL applies when no expiry is asked for; longer ones than
+	// MaxTTL are refused.
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+}
+
+// ShareLinkService lets owners hand out links to their documents that work
+// without an account. A link token is <id>.<expiry>.<signature>, so forged
+// or expired tokens are turned away without a database lookup.
+type ShareLinkService struct {
+	links    shareLinkRepository
+	docs     documentGetter
+	verifier tokenVerifier
+	policy   ShareLinkPolicy
+}
+
+func NewShareLinkService(links shareLinkRepository, docs documentGetter, verifier tokenVerifier, policy ShareLinkPolicy) *ShareLinkService {
+	return &ShareLinkService{links: links, docs: docs, verifier: verifier, policy: policy}
+}
+
+// Create issues a link to the document. A zero ttl means the default, a zero
+// maxDownloads no limit and an empty password no password.
+func (s *ShareLinkService) Create(ctx context.Context, token, docID string, ttl time.Duration, password string, maxDownloads int) (*models.ShareLink, error) {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := s.docs.GetByID(ctx, docID)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, ErrNotFound
+	}
+	if !authorize(session, doc, permManage) {
+		return nil, ErrAccessDenied
+	}
+
+	if ttl == 0 {
+		ttl = s.policy.DefaultTTL
+	}
+	if ttl < 0 || ttl > s.policy.MaxTTL || maxDownloads < 0 {
+		return nil, ErrInvalidShareLink
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+
+	l := &models.ShareLink{
+		ID:           base64.RawURLEncoding.EncodeToString(buf),
+		DocumentID:   doc.ID,
+		DocumentName: doc.Name,
+		CreatedBy:    session.Login,
+		ExpiresAt:    time.Now().Add(ttl).UTC().Truncate(time.Second),
+	}
+	if maxDownloads > 0 {
+		l.MaxDownloads = &maxDownloads
+	}
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		l.PasswordHash = string(hash)
+	}
+
+	if err := s.links.Create(ctx, l); err != nil {
+		return nil, err
+	}
+	l.Token = s.sign(l.ID, l.ExpiresAt)
+	return l, nil
+}
+
+// List returns the links the caller has issued with their tokens.
+func (s *ShareLinkService) List(ctx context.Context, token string) ([]models.ShareLink, error) {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	links, err := s.links.ListByCreator(ctx, session.Login)
+	if err != nil {
+		return nil, err
+	}
+	for i := range links {
+		links[i].Token = s.sign(links[i].ID, links[i].ExpiresAt)
+	}
+	return links, nil
+}
+
+// Revoke deletes a link the caller has issued.
+func (s *ShareLinkService) Revoke(ctx context.Context, token, id string) error {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return err
+	}
+	return notFound(s.links.Delete(ctx, id, session.Login))
+}
+
+// Open resolves a link token to its document. With count set the download
+// is counted against the link's limit; HEAD requests and ranged requests
+// that continue a download past its first byte leave it unset.
+func (s *ShareLinkService) Open(ctx context.Context, linkToken, password string, count bool) (*models.Document, error) {
+	id, expiresAt, ok := s.verify(linkToken)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !time.Now().Before(expiresAt) {
+		return nil, ErrShareLinkExpired
+	}
+
+	l, err := s.links.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		return nil, ErrNotFound
+	}
+	if l.HasPassword() && bcrypt.CompareHashAndPassword([]byte(l.PasswordHash), []byte(password)) != nil {
+		return nil, ErrShareLinkPassword
+	}
+
+	if count {
+		ok, err := s.links.Consume(ctx, l.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrShareLinkExpired
+		}
+	} else if l.MaxDownloads != nil && l.Downloads >= *l.MaxDownloads {
+		return nil, ErrShareLinkExpired
+	}
+
+	doc, err := s.docs.GetByID(ctx, l.DocumentID)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return nil, ErrNotFound
+	}
+	return doc, nil
+}
+
+func (s *ShareLinkService) sign(id string, expiresAt time.Time) string {
+	payload := id + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+func (s *ShareLinkService) verify(linkToken string) (string, time.Time, bool) {
+	parts := strings.Split(linkToken, ".")
+	if len(parts) != 3 {
+		return "", time.Time{}, false
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, s.mac(parts[0]+"."+parts[1])) {
+		return "", time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return parts[0], time.Unix(unix, 0), true
+}
+
+func (s *ShareLinkService) mac(payload string) []byte {
+	m := hmac.New(sha256.New, s.policy.Secret)
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
\ No newline at end of file
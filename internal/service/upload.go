@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	models "docs_storage/internal/models"
+)
+
+var (
+	ErrUploadTooLarge    = errors.New("upload exceeds maximum size")
+	ErrUploadOffset      = errors.New("upload offset mismatch")
+	ErrUploadLocked      = errors.New("upload is being written by another request")
+	ErrInvalidUploadMeta = errors.New("invalid upload metadata")
+)
+
+type uploadStore interface {
+	Create(u *models.Upload) error
+	Get(id string) (*models.Upload, error)
+	Append(u *models.Upload, r io.Reader) (int64, error)
+	Open(id string) (io.ReadCloser, error)
+	Delete(id string) error
+	Idle(before time.Time) ([]string, error)
+}
+
+type documentCreator interface {
+	Create(ctx context.Context, meta *models.Document, file io.Reader, jsonData []byte, token string) (*models.Document, error)
+}
+
+// UploadService runs tus uploads. Its locks are held in memory, which is
+// enough since an upload lives on one instance's disk, see
+// storage.UploadStore.
+type UploadService struct {
+	uploads    uploadStore
+	docs       documentCreator
+	verifier   tokenVerifier
+	maxSize    int64
+	expiration time.Duration
+
+	mu     sync.Mutex
+	active map[string]bool
+}
+
+func NewUploadService(uploads uploadStore, docs documentCreator, verifier tokenVerifier, maxSize int64, expiration time.Duration) *UploadService {
+	return &UploadService{
+		uploads:    uploads,
+		docs:       docs,
+		verifier:   verifier,
+		maxSize:    maxSize,
+		expiration: expiration,
+		active:     make(map[string]bool),
+	}
+}
+
+func (s *UploadService) MaxSize() int64 {
+	return s.maxSize
+}
+
+func (s *UploadService) ExpiresAt(u *models.Upload) time.Time {
+	if s.expiration <= 0 {
+		return time.Time{}
+	}
+	return u.UpdatedAt.Add(s.expiration)
+}
+
+func (s *UploadService) Create(ctx context.Context, token string, length int64, metadata map[string]string) (*models.Upload, error) {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !canUpload(session) {
+		return nil, ErrAccessDenied
+	}
+
+	if s.maxSize > 0 && length > s.maxSize {
+		return nil, ErrUploadTooLarge
+	}
+
+	var meta models.Document
+	if err := json.Unmarshal([]byte(metadata["meta"]), &meta); err != nil {
+		return nil, ErrInvalidUploadMeta
+	}
+	if jsonData, ok := metadata["json"]; ok && !json.Valid([]byte(jsonData)) {
+		return nil, ErrInvalidUploadMeta
+	}
+
+	now := time.Now()
+	u := &models.Upload{
+		ID:         uuid.New().String(),
+		OwnerLogin: session.Login,
+		Length:     length,
+		Metadata:   metadata,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.uploads.Create(u); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+func (s *UploadService) Get(ctx context.Context, token, id string) (*models.Upload, error) {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.get(session, id)
+}
+
+// Append writes the next chunk of an upload starting at offset. When the
+// chunk completes the upload, the data is turned into a document through
+// DocsService and the partial upload is removed; the created document is
+// returned alongside the final state of the upload.
+func (s *UploadService) Append(ctx context.Context, token, id string, offset int64, r io.Reader) (*models.Upload, *models.Document, error) {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !s.lock(id) {
+		return nil, nil, ErrUploadLocked
+	}
+	defer s.unlock(id)
+
+	u, err := s.get(session, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if offset != u.Offset {
+		return u, nil, ErrUploadOffset
+	}
+
+	u.Offset, err = s.uploads.Append(u, r)
+	if err != nil {
+		return u, nil, err
+	}
+	if u.Offset < u.Length {
+		return u, nil, nil
+	}
+
+	doc, err := s.finalize(ctx, token, u)
+	if err != nil {
+		return u, nil, err
+	}
+	return u, doc, nil
+}
+
+func (s *UploadService) Terminate(ctx context.Context, token, id string) error {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if !s.lock(id) {
+		return ErrUploadLocked
+	}
+	defer s.unlock(id)
+
+	if _, err := s.get(session, id); err != nil {
+		return err
+	}
+	return s.uploads.Delete(id)
+}
+
+// RunSweeper periodically removes uploads that have been idle for longer than
+// the configured expiration, until ctx is cancelled.
+func (s *UploadService) RunSweeper(ctx context.Context, interval time.Duration, onError func(error)) {
+	if s.expiration <= 0 || interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.sweep(time.Now().Add(-s.expiration)); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// sweep removes the uploads idle since before. Each one is locked like a
+// PATCH would, so that an upload a client resumes meanwhile is not pulled
+// from under it.
+func (s *UploadService) sweep(before time.Time) error {
+	ids, err := s.uploads.Idle(before)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if !s.lock(id) {
+			continue
+		}
+		u, err := s.uploads.Get(id)
+		if err == nil && u != nil && u.UpdatedAt.After(before) {
+			s.unlock(id)
+			continue
+		}
+		err = s.uploads.Delete(id)
+		s.unlock(id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *UploadService) get(session *models.Session, id string) (*models.Upload, error) {
+	u, err := s.uploads.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || u.OwnerLogin != session.Login {
+		return nil, ErrNotFound
+	}
+	return u, nil
+}
+
+func (s *UploadService) finalize(ctx context.Context, token string, u *models.Upload) (*models.Document, error) {
+	var meta models.Document
+	if err := json.Unmarshal([]byte(u.Metadata["meta"]), &meta); err != nil {
+		return nil, ErrInvalidUploadMeta
+	}
+	meta.File = true
+
+	var jsonData []byte
+	if v, ok := u.Metadata["json"]; ok {
+		jsonData = []byte(v)
+	}
+
+	data, err := s.uploads.Open(u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+
+	doc, err := s.docs.Create(ctx, &meta, data, jsonData, token)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = s.uploads.Delete(u.ID)
+	return doc, nil
+}
+
+func (s *UploadService) lock(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active[id] {
+		return false
+	}
+	s.active[id] = true
+	return true
+}
+
+func (s *UploadService) unlock(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.active, id)
+}
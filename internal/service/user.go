@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+
+	models "docs_storage/internal/models"
+	repository "docs_storage/internal/repository"
+)
+
+var (
+	ErrEmptyPassword = errors.New("password must not be empty")
+	ErrSelfAction    = errors.New("cannot perform this action on your own account")
+)
+
+type userAdminRepository interface {
+	GetByID(ctx context.Context, id int) (*models.User, error)
+	GetByLogin(ctx context.Context, login string) (*models.User, error)
+	List(ctx context.Context, limit, offset int) ([]models.User, int, error)
+	SetDisabled(ctx context.Context, id int, disabled bool) error
+	UpdatePassword(ctx context.Context, id int, passwordHash string) error
+	Delete(ctx context.Context, id int) error
+}
+
+type userSessionRepository interface {
+	DeleteByUser(ctx context.Context, userID int) error
+}
+
+type documentOwnerManager interface {
+	DeleteOwnedBy(ctx context.Context, login string) error
+	ReassignOwner(ctx context.Context, from, to string) error
+	ForgetGrantee(ctx context.Context, login string)
+}
+
+type UserService struct {
+	users    userAdminRepository
+	sessions userSessionRepository
+	verifier tokenVerifier
+	docs     documentOwnerManager
+}
+
+func NewUserService(users userAdminRepository, sessions userSessionRepository, verifier tokenVerifier, docs documentOwnerManager) *UserService {
+	return &UserService{users: users, sessions: sessions, verifier: verifier, docs: docs}
+}
+
+func (s *UserService) List(ctx context.Context, token string, limit, offset int) ([]models.User, int, error) {
+	if _, err := s.requireAdmin(ctx, token); err != nil {
+		return nil, 0, err
+	}
+	return s.users.List(ctx, limit, offset)
+}
+
+// SetDisabled blocks or unblocks an account. Disabling also drops all of the
+// user's sessions so that tokens already handed out stop working; JWT access
+// tokens run out on their own shortly after.
+func (s *UserService) SetDisabled(ctx context.Context, token string, id int, disabled bool) error {
+	admin, err := s.requireAdmin(ctx, token)
+	if err != nil {
+		return err
+	}
+	if admin.UserID == id {
+		return ErrSelfAction
+	}
+
+	if err := s.users.SetDisabled(ctx, id, disabled); err != nil {
+		return notFound(err)
+	}
+	if disabled {
+		return s.sessions.DeleteByUser(ctx, id)
+	}
+	return nil
+}
+
+// Delete removes an account. Its documents are handed over to reassignTo
+// when it is set and deleted otherwise; grants made to it are revoked.
+func (s *UserService) Delete(ctx context.Context, token string, id int, reassignTo string) error {
+	admin, err := s.requireAdmin(ctx, token)
+	if err != nil {
+		return err
+	}
+	if admin.UserID == id {
+		return ErrSelfAction
+	}
+
+	u, err := s.users.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return ErrNotFound
+	}
+
+	if err := s.sessions.DeleteByUser(ctx, id); err != nil {
+		return err
+	}
+
+	if reassignTo != "" {
+		target, err := s.users.GetByLogin(ctx, reassignTo)
+		if err != nil {
+			return err
+		}
+		if target == nil || target.ID == u.ID {
+			return ErrNotFound
+		}
+		if err := s.docs.ReassignOwner(ctx, u.Login, target.Login); err != nil {
+			return err
+		}
+	} else if err := s.docs.DeleteOwnedBy(ctx, u.Login); err != nil {
+		return err
+	}
+
+	if err := s.users.Delete(ctx, id); err != nil {
+		return notFound(err)
+	}
+	s.docs.ForgetGrantee(ctx, u.Login)
+	return nil
+}
+
+func (s *UserService) ResetPassword(ctx context.Context, token string, id int, pswd string) error {
+	if _, err := s.requireAdmin(ctx, token); err != nil {
+		return err
+	}
+
+	if err := s.setPassword(ctx, id, pswd); err != nil {
+		return err
+	}
+	return s.sessions.DeleteByUser(ctx, id)
+}
+
+func (s *UserService) ChangePassword(ctx context.Context, token, oldPswd, newPswd string) error {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return err
+	}
+	if session.IsAPIKey() {
+		return ErrAccessDenied
+	}
+
+	u, err := s.users.GetByID(ctx, session.UserID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return ErrNotFound
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(oldPswd)); err != nil {
+		return ErrAccessDenied
+	}
+
+	return s.setPassword(ctx, u.ID, newPswd)
+}
+
+func (s *UserService) setPassword(ctx context.Context, id int, pswd string) error {
+	if pswd == "" {
+		return ErrEmptyPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pswd), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return notFound(s.users.UpdatePassword(ctx, id, string(hash)))
+}
+
+func (s *UserService) requireAdmin(ctx context.Context, token string) (*models.Session, error) {
+	session, err := s.verifier.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !session.IsAdmin() {
+		return nil, ErrAccessDenied
+	}
+	return session, nil
+}
+
+// notFound translates the repository "not found" error, which is a distinct
+// value from the service one, so callers only have to check for ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
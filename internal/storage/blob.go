@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+
+	models "docs_storage/internal/models"
+)
+
+// BlobStorage stores file contents under their SHA-256 checksum, sharded by
+// the first two bytes of the digest, so identical files share one object.
+// Writing is split in two steps: Stage streams the data to a temporary object
+// while hashing it, and Commit moves it into place unless an object with the
+// same checksum already exists.
+type BlobStorage struct {
+	backend FileStorage
+}
+
+func NewBlobStorage(backend FileStorage) *BlobStorage {
+	return &BlobStorage{backend: backend}
+}
+
+const stagingPrefix = "tmp/"
+
+func (s *BlobStorage) Stage(ctx context.Context, r io.Reader) (*models.Blob, string, error) {
+	tmpKey := stagingPrefix + uuid.New().String()
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	if err := s.backend.Save(ctx, tmpKey, io.TeeReader(r, io.MultiWriter(hash, counter))); err != nil {
+		_ = s.backend.Delete(ctx, tmpKey)
+		return nil, "", err
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	blob := &models.Blob{
+		Checksum: checksum,
+		Key:      BlobKey(checksum),
+		Size:     counter.n,
+	}
+	return blob, tmpKey, nil
+}
+
+func (s *BlobStorage) Commit(ctx context.Context, tmpKey string, blob *models.Blob) error {
+	exists, err := s.backend.Exists(ctx, blob.Key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return s.backend.Delete(ctx, tmpKey)
+	}
+	return s.backend.Move(ctx, tmpKey, blob.Key)
+}
+
+func (s *BlobStorage) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	return s.backend.Open(ctx, key)
+}
+
+func (s *BlobStorage) Delete(ctx context.Context, key string) error {
+	return s.backend.Delete(ctx, key)
+}
+
+// SweepStaged deletes staged objects last written before before, which a
+// crash or a failed Commit left behind, and reports how many.
+func (s *BlobStorage) SweepStaged(ctx context.Context, before time.Time) (int, error) {
+	objects, err := s.backend.List(ctx, stagingPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, obj := range objects {
+		if obj.ModTime.After(before) {
+			continue
+		}
+		if err := s.backend.Delete(ctx, obj.Key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func BlobKey(checksum string) string {
+	return path.Join("sha256", checksum[0:2], checksum[2:4], checksum)
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
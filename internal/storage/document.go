@@ -1,7 +1,12 @@
 package storage
 
 import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 )
 
@@ -13,17 +18,87 @@ func NewLocalFileStorage(basePath string) *LocalFileStorage {
 	return &LocalFileStorage{BasePath: basePath}
 }
 
-func (s *LocalFileStorage) Save(fileName string, data []byte) (string, error) {
-	path := filepath.Join(s.BasePath, fileName)
-	if err := os.WriteFile(path, data, 0644); err != nil {
-		return "", err
+func (s *LocalFileStorage) Save(ctx context.Context, key string, r io.Reader) error {
+	filePath := s.path(key)
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
 	}
-	return path, nil
+
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filePath)
+}
+
+func (s *LocalFileStorage) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	return os.Open(s.path(key))
 }
 
-func (s *LocalFileStorage) Delete(filePath string) error {
-	if filePath == "" {
+func (s *LocalFileStorage) Delete(ctx context.Context, key string) error {
+	if key == "" {
 		return nil
 	}
-	return os.Remove(filePath)
+	return os.Remove(s.path(key))
+}
+
+func (s *LocalFileStorage) Move(ctx context.Context, from, to string) error {
+	target := s.path(to)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.Rename(s.path(from), target)
+}
+
+func (s *LocalFileStorage) Exists(ctx context.Context, key string) (bool, error) {
+	_, err := os.Stat(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
 }
+
+// List walks the directory the prefix names.
+func (s *LocalFileStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	root := s.path(prefix)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.BasePath, p)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, ObjectInfo{Key: filepath.ToSlash(rel), ModTime: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
+
+// path maps an object key onto the storage directory. Cleaning the key as
+// an absolute path keeps it from escaping BasePath with "..".
+func (s *LocalFileStorage) path(key string) string {
+	return filepath.Join(s.BasePath, filepath.FromSlash(path.Clean("/"+key)))
+}
\ No newline at end of file
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+)
+
+// FileStorage is implemented by every storage backend. Objects are addressed
+// by backend-neutral keys such as "sha256/ab/cd/<checksum>", so the same key
+// stays valid after the data is moved to another backend.
+type FileStorage interface {
+	Save(ctx context.Context, key string, r io.Reader) error
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	Delete(ctx context.Context, key string) error
+	Move(ctx context.Context, from, to string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+type ObjectInfo struct {
+	Key     string
+	ModTime time.Time
+}
+
+type Config struct {
+	Backend   string
+	LocalPath string
+	S3        S3Config
+}
+
+func New(ctx context.Context, cfg Config) (FileStorage, error) {
+	switch cfg.Backend {
+	case "", BackendLocal:
+		return NewLocalFileStorage(cfg.LocalPath), nil
+	case BackendS3:
+		return NewS3Storage(ctx, cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.Backend)
+	}
+}
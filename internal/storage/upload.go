@@ -0,0 +1,170 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	models "docs_storage/internal/models"
+)
+
+// UploadStore keeps partial tus uploads on disk: <id>.info holds the upload
+// description as JSON and <id>.bin the bytes received so far. The size of the
+// data file is the upload offset, and its modification time tells the
+// sweeper when the client last sent anything.
+//
+// The disk is always the local one, whatever the storage backend, so with
+// several instances all requests for an upload must reach the instance that
+// created it.
+type UploadStore struct {
+	dir string
+}
+
+func NewUploadStore(basePath string) *UploadStore {
+	return &UploadStore{dir: filepath.Join(basePath, ".uploads")}
+}
+
+func (s *UploadStore) Create(u *models.Upload) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+
+	info, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(s.infoPath(u.ID), info, 0644); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.dataPath(u.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		_ = os.Remove(s.infoPath(u.ID))
+		return err
+	}
+	return f.Close()
+}
+
+func (s *UploadStore) Get(id string) (*models.Upload, error) {
+	if !validUploadID(id) {
+		return nil, nil
+	}
+
+	info, err := os.ReadFile(s.infoPath(id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var u models.Upload
+	if err := json.Unmarshal(info, &u); err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(s.dataPath(id))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	u.Offset = stat.Size()
+	u.UpdatedAt = stat.ModTime()
+
+	return &u, nil
+}
+
+// Append writes r to the end of the upload, never past its declared length,
+// and returns the new offset. Bytes received before a read error are kept so
+// the client can resume from wherever the connection dropped.
+func (s *UploadStore) Append(u *models.Upload, r io.Reader) (int64, error) {
+	f, err := os.OpenFile(s.dataPath(u.ID), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return u.Offset, err
+	}
+
+	n, copyErr := io.Copy(f, io.LimitReader(r, u.Length-u.Offset))
+	closeErr := f.Close()
+
+	offset := u.Offset + n
+	if copyErr != nil {
+		return offset, copyErr
+	}
+	if n == 0 {
+		now := time.Now()
+		_ = os.Chtimes(s.dataPath(u.ID), now, now)
+	}
+	return offset, closeErr
+}
+
+func (s *UploadStore) Open(id string) (io.ReadCloser, error) {
+	if !validUploadID(id) {
+		return nil, fs.ErrNotExist
+	}
+	return os.Open(s.dataPath(id))
+}
+
+func (s *UploadStore) Delete(id string) error {
+	if !validUploadID(id) {
+		return nil
+	}
+
+	if err := os.Remove(s.dataPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Remove(s.infoPath(id)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Idle lists the uploads that have not received any data since before.
+func (s *UploadStore) Idle(before time.Time) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ids []string
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".info")
+		if !ok || !validUploadID(id) {
+			continue
+		}
+
+		modTime := time.Time{}
+		if stat, err := os.Stat(s.dataPath(id)); err == nil {
+			modTime = stat.ModTime()
+		}
+		if modTime.After(before) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (s *UploadStore) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
+
+func (s *UploadStore) dataPath(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+func validUploadID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil && !strings.ContainsAny(id, "/\\.")
+}
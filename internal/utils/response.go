@@ -2,7 +2,9 @@ package utils
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
+	"time"
 
 	"docs_storage/internal/models"
 )
@@ -14,19 +16,60 @@ func WriteJSON(w http.ResponseWriter, status int, payload interface{}) {
 }
 
 type DocResponse struct {
-	ID      string          `json:"id"`
-	Name    string          `json:"name"`
-	Mime    string          `json:"mime"`
-	File    bool            `json:"file"`
-	Public  bool            `json:"public"`
-	Grant   []string        `json:"grant"`
-	Created string          `json:"created"`
-	JSON    json.RawMessage `json:"json_data,omitempty"`
+	ID       string          `json:"id"`
+	Name     string          `json:"name"`
+	Mime     string          `json:"mime"`
+	File     bool            `json:"file"`
+	Public   bool            `json:"public"`
+	Grant    []string        `json:"grant"`
+	ACL      []ACLResponse   `json:"acl"`
+	Created  string          `json:"created"`
+	Version  int             `json:"version"`
+	Checksum string          `json:"checksum,omitempty"`
+	JSON     json.RawMessage `json:"json_data,omitempty"`
+	// ExtractionStatus is pending, done, failed or unsupported for
+	// documents with a file.
+	ExtractionStatus string `json:"extraction_status,omitempty"`
+}
+
+type ACLResponse struct {
+	Grantee     string   `json:"grantee"`
+	Permissions []string `json:"permissions"`
+}
+
+type VersionResponse struct {
+	Version   int             `json:"version"`
+	Mime      string          `json:"mime"`
+	File      bool            `json:"file"`
+	CreatedBy string          `json:"created_by"`
+	Created   string          `json:"created"`
+	Checksum  string          `json:"checksum,omitempty"`
+	JSON      json.RawMessage `json:"json_data,omitempty"`
+}
+
+type GrantsListResponse struct {
+	Data struct {
+		Grants []ACLResponse `json:"grants"`
+	} `json:"data"`
 }
 
 type DocsListResponse struct {
 	Data struct {
-		Docs []DocResponse `json:"docs"`
+		Docs       []DocResponse `json:"docs"`
+		NextCursor string        `json:"next_cursor,omitempty"`
+		Total      *int          `json:"total,omitempty"`
+	} `json:"data"`
+}
+
+type SearchResultResponse struct {
+	DocResponse
+	Rank    float32 `json:"rank"`
+	Snippet string  `json:"snippet"`
+}
+
+type SearchResultsResponse struct {
+	Data struct {
+		Results []SearchResultResponse `json:"results"`
 	} `json:"data"`
 }
 
@@ -34,19 +77,132 @@ type DocDetailResponse struct {
 	Data DocResponse `json:"data"`
 }
 
+type VersionsListResponse struct {
+	Data struct {
+		Versions []VersionResponse `json:"versions"`
+	} `json:"data"`
+}
+
+type VersionDetailResponse struct {
+	Data VersionResponse `json:"data"`
+}
+
+type UserResponse struct {
+	ID       int    `json:"id"`
+	Login    string `json:"login"`
+	Role     string `json:"role"`
+	Disabled bool   `json:"disabled"`
+	Created  string `json:"created"`
+	Source   string `json:"auth_source"`
+}
+
+type UsersListResponse struct {
+	Data struct {
+		Users []UserResponse `json:"users"`
+		Total int            `json:"total"`
+	} `json:"data"`
+}
+
+type APIKeyResponse struct {
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	Prefix   string   `json:"prefix"`
+	Scopes   []string `json:"scopes"`
+	Expires  string   `json:"expires,omitempty"`
+	LastUsed string   `json:"last_used,omitempty"`
+	Created  string   `json:"created"`
+	// Key is only filled in the response to creating the key.
+	Key string `json:"key,omitempty"`
+}
+
+type APIKeysListResponse struct {
+	Data struct {
+		Keys []APIKeyResponse `json:"keys"`
+	} `json:"data"`
+}
+
+type APIKeyDetailResponse struct {
+	Data APIKeyResponse `json:"data"`
+}
+
+type GroupResponse struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Principal   string   `json:"principal"`
+	Description string   `json:"description"`
+	MemberCount int      `json:"member_count"`
+	Members     []string `json:"members,omitempty"`
+	Created     string   `json:"created"`
+}
+
+type GroupsListResponse struct {
+	Data struct {
+		Groups []GroupResponse `json:"groups"`
+	} `json:"data"`
+}
+
+type GroupDetailResponse struct {
+	Data GroupResponse `json:"data"`
+}
+
+type LockoutResponse struct {
+	Kind              string `json:"kind"`
+	Value             string `json:"value"`
+	Failures          int    `json:"failures"`
+	BlockedUntil      string `json:"blocked_until"`
+	RetryAfterSeconds int    `json:"retry_after_seconds"`
+}
+
+type LockoutsListResponse struct {
+	Data struct {
+		Lockouts []LockoutResponse `json:"lockouts"`
+	} `json:"data"`
+}
+
+type ShareLinkResponse struct {
+	ID                string `json:"id"`
+	DocumentID        string `json:"document_id"`
+	DocumentName      string `json:"document_name"`
+	Token             string `json:"token"`
+	URL               string `json:"url"`
+	Expires           string `json:"expires"`
+	MaxDownloads      *int   `json:"max_downloads"`
+	Downloads         int    `json:"downloads"`
+	PasswordProtected bool   `json:"password_protected"`
+	Created           string `json:"created"`
+}
+
+type ShareLinksListResponse struct {
+	Data struct {
+		Links []ShareLinkResponse `json:"links"`
+	} `json:"data"`
+}
+
+type ShareLinkDetailResponse struct {
+	Data ShareLinkResponse `json:"data"`
+}
+
 type DeleteResponse struct {
 	Response map[string]bool `json:"response"`
 }
 
 func ToDocResponse(d models.Document, includeJSON bool) DocResponse {
 	resp := DocResponse{
-		ID:      d.ID,
-		Name:    d.Name,
-		Mime:    d.Mime,
-		File:    d.File,
-		Public:  d.Public,
-		Grant:   d.Grant,
-		Created: d.CreatedAt.Format("2006-01-02 15:04:05"),
+		ID:       d.ID,
+		Name:     d.Name,
+		Mime:     d.Mime,
+		File:     d.File,
+		Public:   d.Public,
+		Grant:    d.Grantees(),
+		ACL:      make([]ACLResponse, 0, len(d.ACL)),
+		Created:  d.CreatedAt.Format("2006-01-02 15:04:05"),
+		Version:  d.Version,
+		Checksum: d.Checksum,
+		// Filled in by the background text extraction.
+		ExtractionStatus: d.ExtractionStatus,
+	}
+	for _, e := range d.ACL {
+		resp.ACL = append(resp.ACL, toACLResponse(e))
 	}
 	if includeJSON && len(d.JSONData) > 0 {
 		resp.JSON = json.RawMessage(d.JSONData)
@@ -54,12 +210,40 @@ func ToDocResponse(d models.Document, includeJSON bool) DocResponse {
 	return resp
 }
 
-func DocsList(docs []models.Document) DocsListResponse {
+func toACLResponse(e models.ACLEntry) ACLResponse {
+	return ACLResponse{Grantee: e.Grantee, Permissions: e.Permissions}
+}
+
+func GrantsList(acl []models.ACLEntry) GrantsListResponse {
+	resp := GrantsListResponse{}
+	resp.Data.Grants = make([]ACLResponse, 0, len(acl))
+	for _, e := range acl {
+		resp.Data.Grants = append(resp.Data.Grants, toACLResponse(e))
+	}
+	return resp
+}
+
+func DocsList(page models.DocumentPage) DocsListResponse {
 	resp := DocsListResponse{}
-	resp.Data.Docs = make([]DocResponse, 0, len(docs))
-	for _, d := range docs {
+	resp.Data.Docs = make([]DocResponse, 0, len(page.Docs))
+	for _, d := range page.Docs {
 		resp.Data.Docs = append(resp.Data.Docs, ToDocResponse(d, false))
 	}
+	resp.Data.NextCursor = page.NextCursor
+	resp.Data.Total = page.Total
+	return resp
+}
+
+func SearchResults(results []models.SearchResult) SearchResultsResponse {
+	resp := SearchResultsResponse{}
+	resp.Data.Results = make([]SearchResultResponse, 0, len(results))
+	for _, r := range results {
+		resp.Data.Results = append(resp.Data.Results, SearchResultResponse{
+			DocResponse: ToDocResponse(r.Document, false),
+			Rank:        r.Rank,
+			Snippet:     r.Snippet,
+		})
+	}
 	return resp
 }
 
@@ -69,6 +253,183 @@ func DocDetail(d models.Document) DocDetailResponse {
 	}
 }
 
+func ToVersionResponse(v models.DocumentVersion, includeJSON bool) VersionResponse {
+	resp := VersionResponse{
+		Version:   v.Version,
+		Mime:      v.Mime,
+		File:      v.File,
+		CreatedBy: v.CreatedBy,
+		Created:   v.CreatedAt.Format("2006-01-02 15:04:05"),
+		Checksum:  v.Checksum,
+	}
+	if includeJSON && len(v.JSONData) > 0 {
+		resp.JSON = json.RawMessage(v.JSONData)
+	}
+	return resp
+}
+
+func VersionsList(versions []models.DocumentVersion) VersionsListResponse {
+	resp := VersionsListResponse{}
+	resp.Data.Versions = make([]VersionResponse, 0, len(versions))
+	for _, v := range versions {
+		resp.Data.Versions = append(resp.Data.Versions, ToVersionResponse(v, false))
+	}
+	return resp
+}
+
+func VersionDetail(v models.DocumentVersion) VersionDetailResponse {
+	return VersionDetailResponse{
+		Data: ToVersionResponse(v, true),
+	}
+}
+
+func UsersList(users []models.User, total int) UsersListResponse {
+	resp := UsersListResponse{}
+	resp.Data.Users = make([]UserResponse, 0, len(users))
+	for _, u := range users {
+		resp.Data.Users = append(resp.Data.Users, UserResponse{
+			ID:       u.ID,
+			Login:    u.Login,
+			Role:     u.Role,
+			Disabled: u.Disabled,
+			Created:  u.CreatedAt.Format("2006-01-02 15:04:05"),
+			Source:   u.AuthSource,
+		})
+	}
+	resp.Data.Total = total
+	return resp
+}
+
+func ToAPIKeyResponse(k models.APIKey) APIKeyResponse {
+	resp := APIKeyResponse{
+		ID:      k.ID,
+		Name:    k.Name,
+		Prefix:  k.Prefix,
+		Scopes:  k.Scopes,
+		Created: k.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+	if k.ExpiresAt != nil {
+		resp.Expires = k.ExpiresAt.Format("2006-01-02 15:04:05")
+	}
+	if k.LastUsedAt != nil {
+		resp.LastUsed = k.LastUsedAt.Format("2006-01-02 15:04:05")
+	}
+	return resp
+}
+
+func APIKeysList(keys []models.APIKey) APIKeysListResponse {
+	resp := APIKeysListResponse{}
+	resp.Data.Keys = make([]APIKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		resp.Data.Keys = append(resp.Data.Keys, ToAPIKeyResponse(k))
+	}
+	return resp
+}
+
+func APIKeyCreated(k models.APIKey, key string) APIKeyDetailResponse {
+	resp := ToAPIKeyResponse(k)
+	resp.Key = key
+	return APIKeyDetailResponse{Data: resp}
+}
+
+func ToGroupResponse(g models.Group) GroupResponse {
+	return GroupResponse{
+		ID:          g.ID,
+		Name:        g.Name,
+		Principal:   models.GroupPrincipal(g.Name),
+		Description: g.Description,
+		MemberCount: g.MemberCount,
+		Members:     g.Members,
+		Created:     g.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func GroupsList(groups []models.Group) GroupsListResponse {
+	resp := GroupsListResponse{}
+	resp.Data.Groups = make([]GroupResponse, 0, len(groups))
+	for _, g := range groups {
+		resp.Data.Groups = append(resp.Data.Groups, ToGroupResponse(g))
+	}
+	return resp
+}
+
+func GroupDetail(g models.Group) GroupDetailResponse {
+	return GroupDetailResponse{Data: ToGroupResponse(g)}
+}
+
+func GroupMemberResp(groupID, userID int, member bool) map[string]any {
+	return map[string]any{
+		"response": map[string]any{"group_id": groupID, "user_id": userID, "member": member},
+	}
+}
+
+func LockoutsList(lockouts []models.Lockout) LockoutsListResponse {
+	resp := LockoutsListResponse{}
+	resp.Data.Lockouts = make([]LockoutResponse, 0, len(lockouts))
+	for _, l := range lockouts {
+		resp.Data.Lockouts = append(resp.Data.Lockouts, LockoutResponse{
+			Kind:              l.Kind,
+			Value:             l.Value,
+			Failures:          l.Failures,
+			BlockedUntil:      l.BlockedUntil.Format("2006-01-02 15:04:05"),
+			RetryAfterSeconds: int(math.Ceil(time.Until(l.BlockedUntil).Seconds())),
+		})
+	}
+	return resp
+}
+
+func ToShareLinkResponse(l models.ShareLink) ShareLinkResponse {
+	return ShareLinkResponse{
+		ID:                l.ID,
+		DocumentID:        l.DocumentID,
+		DocumentName:      l.DocumentName,
+		Token:             l.Token,
+		URL:               "/s/" + l.Token,
+		Expires:           l.ExpiresAt.Format("2006-01-02 15:04:05"),
+		MaxDownloads:      l.MaxDownloads,
+		Downloads:         l.Downloads,
+		PasswordProtected: l.HasPassword(),
+		Created:           l.CreatedAt.Format("2006-01-02 15:04:05"),
+	}
+}
+
+func ShareLinksList(links []models.ShareLink) ShareLinksListResponse {
+	resp := ShareLinksListResponse{}
+	resp.Data.Links = make([]ShareLinkResponse, 0, len(links))
+	for _, l := range links {
+		resp.Data.Links = append(resp.Data.Links, ToShareLinkResponse(l))
+	}
+	return resp
+}
+
+func ShareLinkDetail(l models.ShareLink) ShareLinkDetailResponse {
+	return ShareLinkDetailResponse{Data: ToShareLinkResponse(l)}
+}
+
+func LockoutClearedResp(kind, value string) map[string]any {
+	return map[string]any{
+		"response": map[string]string{"cleared": kind, "value": value},
+	}
+}
+
+func UserStatusResp(id int, disabled bool) map[string]any {
+	return map[string]any{
+		"response": map[string]any{"id": id, "disabled": disabled},
+	}
+}
+
+func IdentityLinkResp(id int, linked bool) map[string]any {
+	return map[string]any{
+		"response": map[string]any{"id": id, "oidc_linked": linked},
+	}
+}
+
+func PasswordChangedResp() map[string]any {
+	return map[string]any{
+		"response": map[string]bool{"password_changed": true},
+	}
+}
+
 func DeleteResp(id string) DeleteResponse {
 	return DeleteResponse{
 		Response: map[string]bool{id: true},
@@ -81,9 +442,46 @@ func RegisterResp(login string) map[string]any {
 	}
 }
 
-func AuthResp(token string) map[string]any {
+func AuthResp(token, refreshToken string, expiresIn int) map[string]any {
+	if refreshToken == "" {
+		return map[string]any{
+			"response": map[string]string{"token": token},
+		}
+	}
+	return map[string]any{
+		"response": map[string]any{
+			"token":         token,
+			"refresh_token": refreshToken,
+			"expires_in":    expiresIn,
+		},
+	}
+}
+
+func MFARequiredResp(mfaToken string, expiresIn int) map[string]any {
+	return map[string]any{
+		"response": map[string]any{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+			"expires_in":   expiresIn,
+		},
+	}
+}
+
+func TOTPEnrollResp(secret, uri string) map[string]any {
+	return map[string]any{
+		"response": map[string]string{"secret": secret, "otpauth_uri": uri},
+	}
+}
+
+func RecoveryCodesResp(codes []string) map[string]any {
+	return map[string]any{
+		"response": map[string]any{"enabled": true, "recovery_codes": codes},
+	}
+}
+
+func TOTPDisabledResp() map[string]any {
 	return map[string]any{
-		"response": map[string]string{"token": token},
+		"response": map[string]bool{"enabled": false},
 	}
 }
 
@@ -10,6 +10,10 @@ func ExtractToken(r *http.Request) string {
 		return token
 	}
 
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+
 	authHeader := r.Header.Get("Authorization")
 	if after, ok :=strings.CutPrefix(authHeader, "Bearer "); ok  {
 		return after
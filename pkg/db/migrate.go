@@ -0,0 +1,97 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// migrationLock is the advisory lock key that keeps instances starting at
+// the same time from applying migrations twice.
+const migrationLock = 7253601
+
+// Migrate applies the *.sql files in dir of fsys that have not been applied
+// yet, in name order, each in its own transaction, and records them in
+// schema_migrations. It returns the names of the files it applied.
+//
+// Databases set up before migrations were recorded have none on record, so
+// every migration must be safe to run again.
+func (p *Postgres) Migrate(ctx context.Context, fsys fs.FS, dir string) ([]string, error) {
+	conn, err := p.Pool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLock); err != nil {
+		return nil, err
+	}
+	defer conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLock)
+
+	if _, err := conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`); err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[string]bool)
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		done[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	files, err := fs.Glob(fsys, path.Join(dir, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var applied []string
+	for _, file := range files {
+		version := strings.TrimSuffix(path.Base(file), ".sql")
+		if done[version] {
+			continue
+		}
+
+		script, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return applied, err
+		}
+
+		tx, err := conn.Begin(ctx)
+		if err != nil {
+			return applied, err
+		}
+		// Without arguments the script goes over the simple protocol, which
+		// takes several statements at once.
+		if _, err := tx.Exec(ctx, string(script)); err != nil {
+			tx.Rollback(ctx)
+			return applied, fmt.Errorf("migration %s: %w", version, err)
+		}
+		if _, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+			tx.Rollback(ctx)
+			return applied, fmt.Errorf("migration %s: %w", version, err)
+		}
+		if err := tx.Commit(ctx); err != nil {
+			return applied, fmt.Errorf("migration %s: %w", version, err)
+		}
+		applied = append(applied, version)
+	}
+	return applied, nil
+}
\ No newline at end of file
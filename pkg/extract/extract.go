@@ -0,0 +1,190 @@
+// Package extract pulls plain text out of uploaded files: PDF, DOCX and
+// anything textual. All extractors are pure Go.
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+const (
+	mimePDF  = "application/pdf"
+	mimeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+	// maxDocumentXML bounds how much of a DOCX body is decompressed, so a
+	// small archive cannot expand without limit.
+	maxDocumentXML = 64 << 20
+)
+
+var (
+	ErrUnsupported = errors.New("unsupported file type")
+	// ErrNotUTF8 is returned for text labelled as UTF-8, or not labelled at
+	// all, that is in some other charset.
+	ErrNotUTF8 = errors.New("text is not valid UTF-8, its charset must be given in the MIME type")
+)
+
+// textTypes are the application/* types that are text already.
+var textTypes = map[string]bool{
+	"application/json":       true,
+	"application/xml":        true,
+	"application/javascript": true,
+	"application/x-yaml":     true,
+}
+
+// Supported reports whether Text can handle files of the MIME type.
+func Supported(mimeType string) bool {
+	switch t := mediaType(mimeType); {
+	case t == mimePDF, t == mimeDOCX:
+		return true
+	default:
+		return isText(t)
+	}
+}
+
+// Text returns the text of a file of the given MIME type, or ErrUnsupported.
+// Textual files are decoded from the charset parameter of the type, UTF-8 by
+// default. The result is valid UTF-8 without NUL characters.
+func Text(data []byte, mimeType string) (text string, err error) {
+	// The PDF reader panics on some malformed files.
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("malformed file: %v", p)
+		}
+	}()
+
+	switch t := mediaType(mimeType); {
+	case t == mimePDF:
+		text, err = pdfText(data)
+	case t == mimeDOCX:
+		text, err = docxText(data)
+	case isText(t):
+		text, err = plainText(data, mimeType)
+	default:
+		return "", ErrUnsupported
+	}
+	if err != nil {
+		return "", err
+	}
+	return clean(text), nil
+}
+
+func mediaType(mimeType string) string {
+	t, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(mimeType))
+	}
+	return t
+}
+
+func isText(t string) bool {
+	return strings.HasPrefix(t, "text/") || textTypes[t]
+}
+
+func plainText(data []byte, mimeType string) (string, error) {
+	_, params, _ := mime.ParseMediaType(mimeType)
+	charset := strings.ToLower(strings.TrimSpace(params["charset"]))
+	if charset == "" || charset == "utf-8" || charset == "us-ascii" {
+		data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+		if !utf8.Valid(data) {
+			return "", ErrNotUTF8
+		}
+		return string(data), nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return "", fmt.Errorf("unknown charset %q", charset)
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", fmt.Errorf("decode %s text: %w", charset, err)
+	}
+	return string(decoded), nil
+}
+
+func pdfText(data []byte) (string, error) {
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	plain, err := r.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+	buf, err := io.ReadAll(plain)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// docxText reads the body of a Word document, keeping paragraphs, line
+// breaks and tabs.
+func docxText(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	f, err := zr.Open("word/document.xml")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	dec := xml.NewDecoder(io.LimitReader(f, maxDocumentXML))
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteByte('\t')
+			case "br", "cr":
+				b.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// clean makes text storable in PostgreSQL, which rejects NUL characters and
+// invalid UTF-8. Invalid bytes, which the PDF reader yields for fonts it
+// cannot map, become spaces so that the words around them stay apart.
+func clean(text string) string {
+	if !utf8.ValidString(text) {
+		text = strings.ToValidUTF8(text, " ")
+	}
+	return strings.ReplaceAll(text, "\x00", "")
+}
\ No newline at end of file